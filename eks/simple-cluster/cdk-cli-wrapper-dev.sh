@@ -6,6 +6,8 @@ SHELL_PATH=$(cd "$(dirname "$0")";pwd)
 CDK_CMD=$1
 CDK_ACC="$(aws sts get-caller-identity --output text --query 'Account')"
 CDK_REGION="$(jq -r .context.deploymentRegion ./cdk.json)"
+# Velero replica bucket is deployed to its own region by another stack.
+VELERO_REPLICA_REGION="$(jq -r 'if .context.velero.enabled then .context.velero.replicaRegion // "" else "" end' ./cdk.json)"
 
 # Check execution env.
 if [ -z "$CODEBUILD_BUILD_ID" ]
@@ -17,11 +19,41 @@ then
     echo "Run bootstrap..."
     export CDK_NEW_BOOTSTRAP=1
     npx cdk bootstrap aws://${CDK_ACC}/${CDK_REGION} --cloudformation-execution-policies arn:aws:iam::aws:policy/AdministratorAccess
+    if [ ! -z "$VELERO_REPLICA_REGION" ]; then
+        npx cdk bootstrap aws://${CDK_ACC}/${VELERO_REPLICA_REGION} --cloudformation-execution-policies arn:aws:iam::aws:policy/AdministratorAccess
+    fi
 else
     CDK_REGION=$AWS_DEFAULT_REGION
 fi
 
 # CDK command pre-process.
+# Compile lambda functions.
+pushd ./functions &> /dev/null
+    make
+    result=$?
+popd &> /dev/null
+if [ $result -ne 0 ]; then
+    echo "Failed to build lambda functions."
+    exit $result
+fi
+
+# Build kubectl layer for the cluster's Kubernetes version.
+k8s_version="$(jq -r '.context.kubernetesVersion // "1.30"' ./cdk.json)"
+pushd ./layers/kubectl &> /dev/null
+    make K8S_VERSION=${k8s_version}
+    result=$?
+popd &> /dev/null
+if [ $result -ne 0 ]; then
+    echo "Failed to build kubectl layer."
+    exit $result
+fi
+
+# Pass the Kubernetes version of the deployed cluster, synth refuses to skip minor versions when upgrading.
+eks_cluster_name="$(jq -r .context.clusterName ./cdk.json)-$(jq -r .context.targetArch ./cdk.json)"
+deployed_k8s_version="$(aws eks describe-cluster --region ${CDK_REGION} --name ${eks_cluster_name} --query 'cluster.version' --output text 2>/dev/null)"
+if [ ! -z "$deployed_k8s_version" ]; then
+    set -- "$@" "-c" "deployedKubernetesVersion=${deployed_k8s_version}"
+fi
 
 # Destroy pre-process.
 if [ "$CDK_CMD" == "destroy" ]; then
@@ -37,13 +69,16 @@ if [ "$CDK_CMD" == "destroy" ]; then
 fi
 
 # CDK command.
+# The app has more than one stack with the Velero replica stack, the cluster's stack depends on it.
+if [ ! -z "$VELERO_REPLICA_REGION" ] && [[ "$CDK_CMD" =~ ^(deploy|destroy|diff)$ ]]; then
+    set -- "$@" "--all"
+fi
 # Valid deploymentStage are: [DEV, PROD]
 set -- "$@" "-c" "deploymentStage=DEV" "--outputs-file" "${SHELL_PATH}/cdk.out/cluster-info.json"
 $SHELL_PATH/cdk-cli-wrapper.sh ${CDK_ACC} ${CDK_REGION} "$@"
 cdk_exec_result=$?
 
 # CDK command post-process.
-eks_cluster_name="$(jq -r .context.clusterName ./cdk.json)-$(jq -r .context.targetArch ./cdk.json)"
 init_state_file=$SHELL_PATH/cdk.out/init-state.${CDK_REGION}-${eks_cluster_name}
 if [ $cdk_exec_result -eq 0 ] && [ "$CDK_CMD" == "deploy" ] && [ ! -f "$init_state_file" ]; then
     # Update kubeconfig
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
 
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 
 	"simple-cluster/config"
+	"simple-cluster/constructs/access"
 	"simple-cluster/constructs/addons"
+	"simple-cluster/constructs/nodegroup"
 	"simple-cluster/constructs/vpc"
 )
 
@@ -32,28 +39,73 @@ func NewEksCdkStack(scope constructs.Construct, id string, props *EksCdkStackPro
 	})
 
 	// The code that defines your stack goes here
+	// Refuse Kubernetes version combinations that the compatibility matrix doesn't allow.
+	for _, problem := range config.CheckKubernetesCompatibility(stack) {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(problem))
+	}
+
 	// Create VPC
-	vpc := vpc.NewEksVpc(stack)
+	vpc, podSubnets := vpc.NewEksVpc(stack)
 
 	// Create EKS cluster
-	cluster := createEksCluster(stack, vpc)
+	cluster, nodeSG, nodegroups := createEksCluster(stack, vpc)
 	// NOTE: You MUST install these three addons at cluster creation time.
 	// If you don't, your nodes will failed to register with your cluster.
-	addons.NewEksVpcCni(stack, cluster)
+	addons.NewEksVpcCni(stack, cluster, podSubnets, nodeSG, nodegroups)
 	addons.NewEksKubeProxy(stack, cluster)
 	addons.NewEksCoreDns(stack, cluster)
+	// Service accounts bound by EKS Pod Identity need the agent running on every node.
+	if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+		addons.NewEksPodIdentityAgent(stack, cluster)
+	}
 
-	addons.NewEksEbsCsiDriver(stack, cluster)
+	gp3StorageClass := addons.NewEksEbsCsiDriver(stack, cluster)
+	if config.EfsCsiDriver(stack) {
+		addons.NewEksEfsCsiDriver(stack, cluster, nodeSG)
+	}
 	addons.NewEksMetricsServer(stack, cluster)
-	addons.NewEksClusterAutoscaler(stack, cluster)
-	addons.NewEksLoadBalancerController(stack, cluster)
+	addons.NewEksClusterAutoscaler(stack, cluster, nodegroups)
+	lbcChart := addons.NewEksLoadBalancerController(stack, cluster)
 	addons.NewEksNodeTerminationHandler(stack, cluster)
-	if config.TargetArch(stack) == config.TargetArch_x86 {
-		addons.NewEksExternalDNS(stack, cluster)
+	addons.NewEksExternalDNS(stack, cluster)
+	// Keep X-Ray daemon until its consumers have moved to ADOT collector.
+	if !config.Adot(stack).Enabled || config.Adot(stack).KeepXrayDaemon {
+		addons.NewEksAwsXray(stack, cluster)
+	}
+	if config.Adot(stack).Enabled {
+		addons.NewEksAdot(stack, cluster)
+	}
+	if config.CertManager(stack).Enabled {
+		addons.NewEksCertManager(stack, cluster)
 	}
-	addons.NewEksAwsXray(stack, cluster)
 	addons.NewEksCloudWatchMetrics(stack, cluster)
 	addons.NewEksFluentBit(stack, cluster)
+	if config.PrometheusStack(stack).Enabled {
+		addons.NewEksPrometheusStack(stack, cluster, gp3StorageClass, lbcChart)
+	}
+
+	// Create tenants' namespaces.
+	tenantNamespaces := addons.NewEksTenants(stack, cluster)
+	// Mount secrets of Secrets Manager and SSM into pods.
+	if config.SecretsStore(stack).Enabled {
+		addons.NewEksSecretsStore(stack, cluster, tenantNamespaces)
+	}
+	// Attach security groups to pods' branch ENIs.
+	if config.PodSecurityGroups(stack).Enabled {
+		addons.NewEksPodSecurityGroups(stack, cluster, tenantNamespaces)
+	}
+	// Guard what teams deploy, before workloads are synced.
+	if config.PolicyEngine(stack).Enabled {
+		addons.NewEksPolicyEngine(stack, cluster)
+	}
+	// Sync workloads from Git.
+	if config.GitOps(stack).Engine != config.GitOpsEngine_NONE {
+		addons.NewEksGitOps(stack, cluster)
+	}
+	// Back up the cluster by schedule.
+	if config.Velero(stack).Enabled {
+		addons.NewEksVelero(stack, cluster)
+	}
 
 	// Output cluster info.
 	awscdk.NewCfnOutput(stack, jsii.String("clusterName"), &awscdk.CfnOutputProps{
@@ -78,58 +130,141 @@ func NewEksCdkStack(scope constructs.Construct, id string, props *EksCdkStackPro
 	return stack
 }
 
-func createEksCluster(stack awscdk.Stack, vpc awsec2.Vpc) awseks.Cluster {
+func createEksCluster(stack awscdk.Stack, vpc awsec2.Vpc) (awseks.Cluster, awsec2.SecurityGroup, []addons.AutoscaledNodegroup) {
 	// Create NodeGroup security group.
 	nodeSG := awsec2.NewSecurityGroup(stack, jsii.String("NodeSG"), &awsec2.SecurityGroupProps{
-		Vpc:              vpc,
-		AllowAllOutbound: jsii.Bool(true),
-		Description:      jsii.String("EKS worker nodes communicate with external."),
+		Vpc:                  vpc,
+		AllowAllOutbound:     jsii.Bool(true),
+		AllowAllIpv6Outbound: jsii.Bool(config.Ipv6(stack)),
+		Description:          jsii.String("EKS worker nodes communicate with external."),
 	})
 	nodeSG.Connections().AllowFrom(nodeSG, awsec2.Port_AllTraffic(),
 		jsii.String("Allow all nodes communicate each other with the this SG."))
-	nodeSG.AddIngressRule(
-		awsec2.Peer_AnyIpv4(),
-		awsec2.NewPort(&awsec2.PortProps{
+	// NodePorts of instance targets and common app ports.
+	nodePorts := []struct {
+		port        awsec2.Port
+		description string
+	}{
+		{awsec2.NewPort(&awsec2.PortProps{
 			Protocol:             awsec2.Protocol_TCP,
 			FromPort:             jsii.Number(30000),
 			ToPort:               jsii.Number(32767),
 			StringRepresentation: jsii.String("Receive K8s NodePort requests."),
-		}),
-		jsii.String("Allow requests to K8s NodePort range."),
-		jsii.Bool(false))
-	nodeSG.AddIngressRule(
-		awsec2.Peer_AnyIpv4(),
-		awsec2.NewPort(&awsec2.PortProps{
+		}), "Allow requests to K8s NodePort range."},
+		{awsec2.NewPort(&awsec2.PortProps{
 			Protocol:             awsec2.Protocol_TCP,
 			FromPort:             jsii.Number(8000),
 			ToPort:               jsii.Number(9000),
 			StringRepresentation: jsii.String("Receive HTTP requests."),
-		}),
-		jsii.String("Allow requests to common app range."),
-		jsii.Bool(false))
+		}), "Allow requests to common app range."},
+	}
+	var nodePeers []awsec2.IPeer
+	nodeIngress := config.NodeIngress(stack)
+	for _, cidr := range nodeIngress.Cidrs {
+		if strings.Contains(cidr, ":") {
+			nodePeers = append(nodePeers, awsec2.Peer_Ipv6(jsii.String(cidr)))
+		} else {
+			nodePeers = append(nodePeers, awsec2.Peer_Ipv4(jsii.String(cidr)))
+		}
+	}
+	for _, prefixListId := range nodeIngress.PrefixListIds {
+		nodePeers = append(nodePeers, awsec2.Peer_PrefixList(jsii.String(prefixListId)))
+	}
+	// AWS Load Balancer Controller attaches this security group to all its load balancers.
+	if nodeIngress.LoadBalancers {
+		nodePeers = append(nodePeers, awsec2.NewSecurityGroup(stack, jsii.String("LoadBalancerSG"), &awsec2.SecurityGroupProps{
+			Vpc:                  vpc,
+			AllowAllOutbound:     jsii.Bool(true),
+			AllowAllIpv6Outbound: jsii.Bool(config.Ipv6(stack)),
+			Description:          jsii.String("Load balancers of AWS Load Balancer Controller reach nodes and pods."),
+		}))
+		// NLBs have no security groups before chart 1.5 (Kubernetes 1.25), their traffic comes from the VPC CIDR.
+		if config.KubernetesMinorVersion(stack) < 25 && !slices.Contains(nodeIngress.Cidrs, config.VpcCidr) {
+			nodePeers = append(nodePeers, awsec2.Peer_Ipv4(jsii.String(config.VpcCidr)))
+		}
+	}
+	for _, peer := range nodePeers {
+		for _, nodePort := range nodePorts {
+			nodeSG.AddIngressRule(peer, nodePort.port, jsii.String(nodePort.description), jsii.Bool(false))
+		}
+	}
 
 	// Creating Nodegroup in private subnet only when deployment cluster in PROD stage.
-	subnetType := awsec2.SubnetType_PUBLIC
+	// Private subnets are selected by group name, they're isolated subnets in none+endpoints NAT mode.
+	subnetGroupName := config.PublicSubnetGroupName
 	if config.DeploymentStage(stack) == config.DeploymentStage_PROD {
-		subnetType = awsec2.SubnetType_PRIVATE_WITH_NAT
+		subnetGroupName = config.PrivateSubnetGroupName
+		if config.NatMode(stack) == config.NatMode_NONE_ENDPOINTS {
+			awscdk.Annotations_Of(stack).AddWarning(jsii.String(
+				"Private subnets have no NAT in none+endpoints mode, images and Helm charts of public registries must be mirrored to ECR."))
+		}
 	}
 	// Create EKS cluster.
 	showCfgCmd := false
-	if len(config.MasterUsers(stack)) == 0 {
+	if len(config.AccessEntries(stack)) == 0 {
 		showCfgCmd = true
 	}
+	// Create KMS key for envelope encryption of K8s secrets.
+	var secretsKey awskms.IKey = nil
+	if config.SecretsEncryption(stack) {
+		secretsKey = awskms.NewKey(stack, jsii.String("ClusterSecretsKey"), &awskms.KeyProps{
+			Alias:             jsii.String(*stack.StackName() + "-ClusterSecretsKey"),
+			Description:       jsii.String("Envelope encryption of K8s secrets in EKS cluster " + config.ClusterName(stack)),
+			EnableKeyRotation: jsii.Bool(true),
+			PendingWindow:     awscdk.Duration_Days(jsii.Number(7)),
+			RemovalPolicy:     awscdk.RemovalPolicy_DESTROY,
+		})
+	}
+	// Private endpoint and restricted public endpoint require private subnets for CDK's kubectl handler.
+	endpointAccess := awseks.EndpointAccess_PUBLIC_AND_PRIVATE()
+	switch config.EndpointAccess(stack) {
+	case config.EndpointAccess_PUBLIC:
+		endpointAccess = awseks.EndpointAccess_PUBLIC()
+	case config.EndpointAccess_PRIVATE:
+		endpointAccess = awseks.EndpointAccess_PRIVATE()
+	}
+	publicAccessCidrs := config.EndpointPublicAccessCidrs(stack)
+	if len(publicAccessCidrs) > 0 && config.EndpointAccess(stack) != config.EndpointAccess_PRIVATE {
+		endpointAccess = endpointAccess.OnlyFrom(*jsii.Strings(publicAccessCidrs...)...)
+	}
+	if config.DeploymentStage(stack) != config.DeploymentStage_PROD &&
+		(config.EndpointAccess(stack) == config.EndpointAccess_PRIVATE || len(publicAccessCidrs) > 0) {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(
+			"Private endpoint access and public access CIDRs require private subnets, which are created in PROD stage only."))
+		endpointAccess = awseks.EndpointAccess_PUBLIC_AND_PRIVATE()
+	}
+	// Pods and services of IPv6 family get IPv6 addresses from the dual-stack subnets.
+	ipFamily := awseks.IpFamily_IP_V4
+	if config.Ipv6(stack) {
+		ipFamily = awseks.IpFamily_IP_V6
+		if len(config.PodCidr(stack)) > 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("VPC CNI custom networking doesn't support IPv6, set podCidr to empty."))
+		}
+		if len(config.CustomAmiId(stack)) > 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(
+				"Nodes of a custom AMI bootstrap with IPv4 cluster DNS IP and service CIDR, set customAmiId to empty for IPv6."))
+		}
+	}
 	cluster := awseks.NewCluster(stack, jsii.String("EksCluster"), &awseks.ClusterProps{
 		ClusterName: jsii.String(config.ClusterName(stack)),
-		Version:     awseks.KubernetesVersion_V1_21(),
+		Version:     awseks.KubernetesVersion_Of(jsii.String(config.KubernetesVersion(stack))),
 		Vpc:         vpc,
 		VpcSubnets: &[]*awsec2.SubnetSelection{
 			{
-				SubnetType: subnetType,
+				SubnetGroupName: jsii.String(subnetGroupName),
 			},
 		},
-		DefaultCapacity:     jsii.Number(0), // Disable creation of default node group.
-		OutputConfigCommand: jsii.Bool(showCfgCmd),
-		SecurityGroup:       nodeSG, // Set additional cluster security group.
+		DefaultCapacity:      jsii.Number(0), // Disable creation of default node group.
+		OutputConfigCommand:  jsii.Bool(showCfgCmd),
+		SecurityGroup:        nodeSG, // Set additional cluster security group.
+		SecretsEncryptionKey: secretsKey,
+		EndpointAccess:       endpointAccess,
+		IpFamily:             ipFamily,
+		AuthenticationMode:   awseks.AuthenticationMode(config.AuthenticationMode(stack)),
+		KubectlLayer: awslambda.NewLayerVersion(stack, jsii.String("KubectlLayer"), &awslambda.LayerVersionProps{
+			Code:        awslambda.AssetCode_FromAsset(jsii.String(config.KubectlLayerCodePath), nil),
+			Description: jsii.String("kubectl and helm for Kubernetes " + config.KubernetesVersion(stack)),
+		}),
 	})
 
 	// Create cluster node role.
@@ -148,65 +283,218 @@ func createEksCluster(stack awscdk.Stack, vpc awsec2.Vpc) awseks.Cluster {
 		keyPair = jsii.String(config.KeyPairName(stack))
 	}
 
-	amiType := awseks.NodegroupAmiType_AL2_X86_64
+	// Pin nodegroups' AMI release version, nodegroups are rolled when it changes.
+	var releaseVersion *string = nil
+	if len(config.AmiReleaseVersion(stack)) > 0 {
+		releaseVersion = jsii.String(config.AmiReleaseVersion(stack))
+	}
+
 	instanceClass := awsec2.InstanceClass_COMPUTE5
 	if config.TargetArch(stack) == config.TargetArch_arm {
-		amiType = awseks.NodegroupAmiType_AL2_ARM_64
 		instanceClass = awsec2.InstanceClass_STANDARD6_GRAVITON
 	}
+	instanceType := awsec2.InstanceType_Of(instanceClass, awsec2.InstanceSize_LARGE)
+	// Spot nodegroup is diversified over instance types of the same vCPUs and memory.
+	spotInstanceTypeNames := config.SpotInstanceTypes(stack)
+	if err := nodegroup.CheckInstanceTypes(spotInstanceTypeNames, config.TargetArch(stack)); err != nil {
+		awscdk.Annotations_Of(stack).AddError(jsii.String("Spot instance types are not valid: " + err.Error()))
+		// CDK refuses instance types of different architectures before the error is reported.
+		spotInstanceTypeNames = []string{*instanceType.ToString()}
+	}
+	var spotInstanceTypes []awsec2.InstanceType
+	for _, name := range spotInstanceTypeNames {
+		spotInstanceTypes = append(spotInstanceTypes, awsec2.NewInstanceType(jsii.String(name)))
+	}
 
-	// Create On-Demand Instance Nodegroup Launch Template.
-	onDemandNgLt := awsec2.NewLaunchTemplate(stack, jsii.String("OnDemandNodegroupLT"), &awsec2.LaunchTemplateProps{
-		BlockDevices: &[]*awsec2.BlockDevice{
-			{
-				DeviceName: jsii.String("/dev/xvda"),
-				Volume: awsec2.BlockDeviceVolume_Ebs(jsii.Number(100), &awsec2.EbsDeviceOptions{
-					DeleteOnTermination: jsii.Bool(true),
-					VolumeType:          awsec2.EbsDeviceVolumeType_GP3,
-					Encrypted:           jsii.Bool(false),
-				}),
+	// Nodegroups of a custom AMI get the AMI from launch templates, they must not set AMI type.
+	amiFamily := config.AmiFamily(stack)
+	customAmiId := config.CustomAmiId(stack)
+	amiType := nodegroup.AmiType(amiFamily, config.TargetArch(stack))
+	var imageId *string = nil
+	if len(customAmiId) > 0 {
+		amiType = ""
+		imageId = jsii.String(customAmiId)
+	}
+
+	// IPv6 pods always get IPs from prefixes.
+	maxPods := func(instanceTypes []string) int {
+		pods, err := nodegroup.MaxPods(instanceTypes, len(config.PodCidr(stack)) > 0,
+			config.PrefixDelegation(stack) || config.Ipv6(stack))
+		if err != nil {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(err.Error()))
+		}
+		return pods
+	}
+	kubeletExtraArgs := config.KubeletExtraArgs(stack)
+	if amiFamily == config.AmiFamily_BOTTLEROCKET && len(kubeletExtraArgs) > 0 {
+		awscdk.Annotations_Of(stack).AddWarning(jsii.String("Bottlerocket doesn't accept kubelet flags, kubeletExtraArgs are ignored."))
+	}
+	userData := func(nodegroupName string, capacityType awseks.CapacityType, instanceTypes []string) *string {
+		var nodeTaints map[string]string = nil
+		if capacityType == awseks.CapacityType_SPOT {
+			nodeTaints = map[string]string{
+				config.SpotTaintKey: config.SpotTaintValue + ":NoSchedule",
+			}
+		}
+		return nodegroup.NewUserData(&nodegroup.UserDataProps{
+			Cluster:          cluster,
+			AmiFamily:        amiFamily,
+			CustomAmi:        len(customAmiId) > 0,
+			MaxPods:          maxPods(instanceTypes),
+			KubeletExtraArgs: kubeletExtraArgs,
+			NodeLabels: map[string]string{
+				"deployment-stage":                  string(config.DeploymentStage(stack)),
+				"eks.amazonaws.com/nodegroup":       nodegroupName,
+				"eks.amazonaws.com/capacityType":    string(capacityType),
+				"eks.amazonaws.com/nodegroup-image": customAmiId,
 			},
+			NodeTaints: nodeTaints,
+		})
+	}
+
+	// Create KMS key for node volumes.
+	// Nodes are launched by EC2 Auto Scaling, its service-linked role must be allowed to use the key.
+	nodeVolumeKey := awskms.NewKey(stack, jsii.String("NodeVolumeKey"), &awskms.KeyProps{
+		Alias:             jsii.String(*stack.StackName() + "-NodeVolumeKey"),
+		Description:       jsii.String("Encryption of EBS volumes of EKS cluster " + config.ClusterName(stack) + " nodes"),
+		EnableKeyRotation: jsii.Bool(true),
+		PendingWindow:     awscdk.Duration_Days(jsii.Number(7)),
+		RemovalPolicy:     awscdk.RemovalPolicy_DESTROY,
+	})
+	autoScalingRole := awsiam.NewArnPrincipal(jsii.String("arn:" + *stack.Partition() + ":iam::" + *stack.Account() +
+		":role/aws-service-role/autoscaling.amazonaws.com/AWSServiceRoleForAutoScaling"))
+	nodeVolumeKey.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Principals: &[]awsiam.IPrincipal{
+			autoScalingRole,
+		},
+		Actions: &[]*string{
+			jsii.String("kms:Encrypt"),
+			jsii.String("kms:Decrypt"),
+			jsii.String("kms:ReEncrypt*"),
+			jsii.String("kms:GenerateDataKey*"),
+			jsii.String("kms:DescribeKey"),
+		},
+		Resources: &[]*string{
+			jsii.String("*"),
 		},
-		LaunchTemplateName: jsii.String(*stack.StackName() + "-OnDemandNodegroupLT"),
-		SecurityGroup:      nodeSG,
-		KeyName:            keyPair,
+	}), nil)
+	nodeVolumeKey.AddToResourcePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Principals: &[]awsiam.IPrincipal{
+			autoScalingRole,
+		},
+		Actions: &[]*string{
+			jsii.String("kms:CreateGrant"),
+		},
+		Resources: &[]*string{
+			jsii.String("*"),
+		},
+		Conditions: &map[string]interface{}{
+			"Bool": map[string]interface{}{
+				"kms:GrantIsForAWSResource": true,
+			},
+		},
+	}), nil)
+
+	// Node volumes are KMS encrypted gp3.
+	var onDemandBlockDevices []*awsec2.BlockDevice
+	var spotBlockDevices []*awsec2.CfnLaunchTemplate_BlockDeviceMappingProperty
+	var dataVolumeIndex int
+	for i, volume := range nodegroup.Volumes(amiFamily, config.NodeVolumeSize(stack)) {
+		var iops, throughput *float64 = nil, nil
+		if volume.Data {
+			dataVolumeIndex = i
+			iops = jsii.Number(config.NodeVolumeIops(stack))
+			throughput = jsii.Number(config.NodeVolumeThroughput(stack))
+		}
+
+		onDemandBlockDevices = append(onDemandBlockDevices, &awsec2.BlockDevice{
+			DeviceName: jsii.String(volume.DeviceName),
+			Volume: awsec2.BlockDeviceVolume_Ebs(jsii.Number(volume.Size), &awsec2.EbsDeviceOptions{
+				DeleteOnTermination: jsii.Bool(true),
+				VolumeType:          awsec2.EbsDeviceVolumeType_GP3,
+				Iops:                iops,
+				Encrypted:           jsii.Bool(true),
+				KmsKey:              nodeVolumeKey,
+			}),
+		})
+		spotBlockDevices = append(spotBlockDevices, &awsec2.CfnLaunchTemplate_BlockDeviceMappingProperty{
+			DeviceName: jsii.String(volume.DeviceName),
+			Ebs: awsec2.CfnLaunchTemplate_EbsProperty{
+				DeleteOnTermination: jsii.Bool(true),
+				VolumeType:          jsii.String("gp3"),
+				VolumeSize:          jsii.Number(volume.Size),
+				Iops:                iops,
+				Throughput:          throughput,
+				Encrypted:           jsii.Bool(true),
+				KmsKeyId:            nodeVolumeKey.KeyArn(),
+			},
+		})
+	}
+
+	// Cluster Autoscaler is configured from these nodegroups.
+	onDemandNg := addons.AutoscaledNodegroup{Name: "OnDemandNodegroup", CapacityType: awseks.CapacityType_ON_DEMAND, MinSize: 1, MaxSize: 3}
+	spotNg := addons.AutoscaledNodegroup{Name: "SpotNodegroup", CapacityType: awseks.CapacityType_SPOT, MinSize: 1, MaxSize: 3}
+
+	// Create On-Demand Instance Nodegroup Launch Template.
+	onDemandNgLt := awsec2.NewLaunchTemplate(stack, jsii.String("OnDemandNodegroupLT"), &awsec2.LaunchTemplateProps{
+		BlockDevices: &onDemandBlockDevices,
+		// Require IMDSv2, hop limit 2 lets containers without host network reach IMDS.
+		HttpTokens:              awsec2.LaunchTemplateHttpTokens_REQUIRED,
+		HttpPutResponseHopLimit: jsii.Number(2),
+		DetailedMonitoring:      jsii.Bool(config.NodeDetailedMonitoring(stack)),
+		LaunchTemplateName:      jsii.String(*stack.StackName() + "-OnDemandNodegroupLT"),
+		SecurityGroup:           nodeSG,
+		KeyName:                 keyPair,
+		UserData:                awsec2.UserData_Custom(userData("OnDemandNodegroup", awseks.CapacityType_ON_DEMAND, []string{*instanceType.ToString()})),
 	})
+	// LaunchTemplate doesn't support gp3 throughput and image id yet.
+	onDemandNgLtRes := onDemandNgLt.Node().DefaultChild().(awsec2.CfnLaunchTemplate)
+	onDemandNgLtRes.AddPropertyOverride(
+		jsii.String(fmt.Sprintf("LaunchTemplateData.BlockDeviceMappings.%d.Ebs.Throughput", dataVolumeIndex)),
+		jsii.Number(config.NodeVolumeThroughput(stack)))
+	if imageId != nil {
+		onDemandNgLtRes.AddPropertyOverride(jsii.String("LaunchTemplateData.ImageId"), imageId)
+	}
 	// Add On-Demand Instance Nodegroup.
-	cluster.AddNodegroupCapacity(jsii.String("OnDemandNodegroup"), &awseks.NodegroupOptions{
+	cluster.AddNodegroupCapacity(jsii.String(onDemandNg.Name), &awseks.NodegroupOptions{
 		AmiType:      amiType,
-		CapacityType: awseks.CapacityType_ON_DEMAND,
+		CapacityType: onDemandNg.CapacityType,
 		// DesiredSize:   jsii.Number(2),
-		InstanceTypes: &[]awsec2.InstanceType{awsec2.InstanceType_Of(instanceClass, awsec2.InstanceSize_LARGE)},
+		InstanceTypes: &[]awsec2.InstanceType{instanceType},
 		Labels: &map[string]*string{
 			"deployment-stage": jsii.String(string(config.DeploymentStage(stack))),
 		},
 		LaunchTemplateSpec: &awseks.LaunchTemplateSpec{Id: onDemandNgLt.LaunchTemplateId(), Version: onDemandNgLt.LatestVersionNumber()},
-		MaxSize:            jsii.Number(3),
-		MinSize:            jsii.Number(1),
-		NodegroupName:      jsii.String("OnDemandNodegroup"),
+		MaxSize:            jsii.Number(onDemandNg.MaxSize),
+		MinSize:            jsii.Number(onDemandNg.MinSize),
+		NodegroupName:      jsii.String(onDemandNg.Name),
 		NodeRole:           clusterNodeRole,
+		ReleaseVersion:     releaseVersion,
 		Subnets: &awsec2.SubnetSelection{
-			SubnetType: subnetType,
+			SubnetGroupName: jsii.String(subnetGroupName),
 		},
 	})
 
 	// Create Spot Instance Nodegroup Launch Template.
 	spotNgLt := awsec2.NewCfnLaunchTemplate(stack, jsii.String("SpotNodegroupLT"), &awsec2.CfnLaunchTemplateProps{
 		LaunchTemplateData: awsec2.CfnLaunchTemplate_LaunchTemplateDataProperty{
-			BlockDeviceMappings: &[]*awsec2.CfnLaunchTemplate_BlockDeviceMappingProperty{
-				{
-					DeviceName: jsii.String("/dev/xvda"),
-					Ebs: awsec2.CfnLaunchTemplate_EbsProperty{
-						DeleteOnTermination: jsii.Bool(true),
-						VolumeType:          jsii.String("gp3"),
-						Encrypted:           jsii.Bool(false),
-					},
-				},
+			BlockDeviceMappings: &spotBlockDevices,
+			MetadataOptions: &awsec2.CfnLaunchTemplate_MetadataOptionsProperty{
+				HttpEndpoint:            jsii.String("enabled"),
+				HttpTokens:              jsii.String("required"),
+				HttpPutResponseHopLimit: jsii.Number(2),
+			},
+			Monitoring: &awsec2.CfnLaunchTemplate_MonitoringProperty{
+				Enabled: jsii.Bool(config.NodeDetailedMonitoring(stack)),
 			},
 			SecurityGroupIds: &[]*string{
 				nodeSG.SecurityGroupId(),
 			},
-			KeyName: keyPair,
+			KeyName:  keyPair,
+			ImageId:  imageId,
+			UserData: awscdk.Fn_Base64(userData("SpotNodegroup", awseks.CapacityType_SPOT, spotInstanceTypeNames)),
 			TagSpecifications: &[]*awsec2.CfnLaunchTemplate_TagSpecificationProperty{
 				{
 					ResourceType: jsii.String("instance"),
@@ -222,46 +510,152 @@ func createEksCluster(stack awscdk.Stack, vpc awsec2.Vpc) awseks.Cluster {
 		LaunchTemplateName: jsii.String(*stack.StackName() + "-SpotNodegroupLT"),
 	})
 	// Add Spot Instance Nodegroup.
-	cluster.AddNodegroupCapacity(jsii.String("SpotNodegroup"), &awseks.NodegroupOptions{
+	// EKS creates its Auto Scaling group with capacity optimized allocation and Capacity Rebalancing,
+	// managed nodegroups don't allow changing them.
+	cluster.AddNodegroupCapacity(jsii.String(spotNg.Name), &awseks.NodegroupOptions{
 		AmiType:      amiType,
-		CapacityType: awseks.CapacityType_SPOT,
+		CapacityType: spotNg.CapacityType,
 		// DesiredSize:   jsii.Number(2),
-		InstanceTypes: &[]awsec2.InstanceType{awsec2.InstanceType_Of(instanceClass, awsec2.InstanceSize_LARGE)},
+		InstanceTypes: &spotInstanceTypes,
 		Labels: &map[string]*string{
 			"deployment-stage": jsii.String(string(config.DeploymentStage(stack))),
 		},
+		Taints: &[]*awseks.TaintSpec{
+			{
+				Effect: awseks.TaintEffect_NO_SCHEDULE,
+				Key:    jsii.String(config.SpotTaintKey),
+				Value:  jsii.String(config.SpotTaintValue),
+			},
+		},
 		LaunchTemplateSpec: &awseks.LaunchTemplateSpec{Id: spotNgLt.Ref(), Version: spotNgLt.AttrLatestVersionNumber()},
-		MaxSize:            jsii.Number(3),
-		MinSize:            jsii.Number(1),
-		NodegroupName:      jsii.String("SpotNodegroup"),
+		MaxSize:            jsii.Number(spotNg.MaxSize),
+		MinSize:            jsii.Number(spotNg.MinSize),
+		NodegroupName:      jsii.String(spotNg.Name),
 		NodeRole:           clusterNodeRole,
+		ReleaseVersion:     releaseVersion,
 		Subnets: &awsec2.SubnetSelection{
-			SubnetType: subnetType,
+			SubnetGroupName: jsii.String(subnetGroupName),
 		},
 	})
 
-	// Mapping IAM user to K8s group.
-	for _, userName := range config.MasterUsers(stack) {
-		masterUser := awsiam.User_FromUserName(stack, jsii.String("ClusterMasterUser-"+userName), jsii.String(userName))
-		cluster.AwsAuth().AddUserMapping(masterUser, &awseks.AwsAuthMapping{
-			Groups: &[]*string{
-				jsii.String("system:masters"),
+	// Run pods of the selected namespaces on Fargate.
+	if len(config.FargateProfiles(stack)) > 0 {
+		addFargateProfiles(stack, cluster)
+	}
+
+	// Grant IAM users and roles access to the cluster.
+	access.NewEksAccessEntries(stack, cluster)
+
+	return cluster, nodeSG, []addons.AutoscaledNodegroup{onDemandNg, spotNg}
+}
+
+// Add Fargate profiles of 'cdk.json/context/fargateProfiles', they share a pod execution role.
+// DaemonSets can't run on Fargate, so kube-system without labels only selects CoreDNS and AWS Load Balancer Controller.
+func addFargateProfiles(stack awscdk.Stack, cluster awseks.Cluster) {
+	if config.DeploymentStage(stack) != config.DeploymentStage_PROD {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(
+			"Fargate profiles require private subnets, which are created in PROD stage only."))
+		return
+	}
+	// Pods on Fargate can't reach EKS Pod Identity Agent, which is a DaemonSet.
+	if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+		if config.KubeSystemOnFargate(stack) {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(
+				"AWS Load Balancer Controller on Fargate requires IRSA, set serviceAccountIdentity to IRSA or remove kube-system from fargateProfiles."))
+		} else {
+			awscdk.Annotations_Of(stack).AddWarning(jsii.String(
+				"EKS Pod Identity isn't supported on Fargate, service accounts of pods on Fargate don't get IAM credentials."))
+		}
+	}
+
+	podExecutionRole := awsiam.NewRole(stack, jsii.String("FargatePodExecutionRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("eks-fargate-pods.amazonaws.com"), &awsiam.ServicePrincipalOpts{}),
+		ManagedPolicies: &[]awsiam.IManagedPolicy{
+			awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AmazonEKSFargatePodExecutionRolePolicy")),
+		},
+	})
+
+	for _, profile := range config.FargateProfiles(stack) {
+		if len(profile.Namespace) == 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf("Fargate profile %s has no namespace.", profile.Name)))
+			continue
+		}
+
+		var selectors []*awseks.Selector
+		if profile.Namespace == "kube-system" && len(profile.Labels) == 0 {
+			selectors = []*awseks.Selector{
+				{
+					Namespace: jsii.String(profile.Namespace),
+					Labels:    &map[string]*string{"k8s-app": jsii.String("kube-dns")},
+				},
+				{
+					Namespace: jsii.String(profile.Namespace),
+					Labels:    &map[string]*string{"app.kubernetes.io/name": jsii.String("aws-load-balancer-controller")},
+				},
+			}
+		} else {
+			selector := &awseks.Selector{
+				Namespace: jsii.String(profile.Namespace),
+			}
+			if len(profile.Labels) > 0 {
+				labels := map[string]*string{}
+				for k, v := range profile.Labels {
+					labels[k] = jsii.String(v)
+				}
+				selector.Labels = &labels
+			}
+			selectors = append(selectors, selector)
+		}
+
+		// Fargate pods are always placed in private subnets.
+		cluster.AddFargateProfile(jsii.String("FargateProfile-"+profile.Name), &awseks.FargateProfileOptions{
+			FargateProfileName: jsii.String(profile.Name),
+			Selectors:          &selectors,
+			PodExecutionRole:   podExecutionRole,
+			Vpc:                cluster.Vpc(),
+			SubnetSelection: &awsec2.SubnetSelection{
+				SubnetGroupName: jsii.String(config.PrivateSubnetGroupName),
 			},
 		})
 	}
+}
+
+// Stack of the Velero replica bucket, it's deployed to the replica region.
+func NewVeleroReplicaStack(scope constructs.Construct, id string, props *EksCdkStackProps) awscdk.Stack {
+	var sprops awscdk.StackProps
+	if props != nil {
+		sprops = props.StackProps
+	}
+	stack := awscdk.NewStack(scope, &id, &sprops)
+
+	addons.NewVeleroReplicaBucket(stack)
 
-	return cluster
+	return stack
 }
 
 func main() {
 	app := awscdk.NewApp(nil)
 
-	NewEksCdkStack(app, config.StackName(app), &EksCdkStackProps{
+	eksStack := NewEksCdkStack(app, config.StackName(app), &EksCdkStackProps{
 		awscdk.StackProps{
 			Env: env(),
 		},
 	})
 
+	// The replica bucket must exist before the Velero bucket replicates to it.
+	velero := config.Velero(app)
+	if velero.Enabled && len(velero.ReplicaRegion) > 0 {
+		replicaStack := NewVeleroReplicaStack(app, config.StackName(app)+"-VeleroReplica", &EksCdkStackProps{
+			awscdk.StackProps{
+				Env: &awscdk.Environment{
+					Account: env().Account,
+					Region:  jsii.String(velero.ReplicaRegion),
+				},
+			},
+		})
+		eksStack.AddDependency(replicaStack, jsii.String("Velero bucket replicates to the replica bucket."))
+	}
+
 	app.Synth(nil)
 }
 
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Cluster Autoscaler expander config
+// PRIORITY prefers nodegroups by the priority-expander ConfigMap, Spot before On-Demand, ties are broken by least waste.
+// LEAST_WASTE selects the nodegroup that will have the least idle CPU (if tied, unused memory) after scale-up.
+type ClusterAutoscalerExpanderType string
+
+const (
+	ClusterAutoscalerExpander_PRIORITY    ClusterAutoscalerExpanderType = "priority"
+	ClusterAutoscalerExpander_LEAST_WASTE ClusterAutoscalerExpanderType = "least-waste"
+)
+
+// Scale-down parameters are left empty to be derived from the deployment stage.
+type ClusterAutoscalerConfig struct {
+	Expander ClusterAutoscalerExpanderType
+	// How long a node should be unneeded before it is eligible for scale down, e.g. 10m.
+	ScaleDownUnneededTime string
+	// How long after scale up that scale down evaluation resumes, e.g. 10m.
+	ScaleDownDelayAfterAdd string
+	// Nodes whose requests are below this ratio of their allocatable resources are considered for scale down, e.g. 0.5.
+	ScaleDownUtilizationThreshold string
+}
+
+// DO NOT modify this function, change Cluster Autoscaler config by 'cdk.json/context/clusterAutoscaler'.
+func ClusterAutoscaler(scope constructs.Construct) ClusterAutoscalerConfig {
+	clusterAutoscaler := ClusterAutoscalerConfig{
+		Expander: ClusterAutoscalerExpander_PRIORITY,
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("clusterAutoscaler"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["expander"].(string); ok && len(v) > 0 {
+			clusterAutoscaler.Expander = ClusterAutoscalerExpanderType(v)
+		}
+		clusterAutoscaler.ScaleDownUnneededTime, _ = values["scaleDownUnneededTime"].(string)
+		clusterAutoscaler.ScaleDownDelayAfterAdd, _ = values["scaleDownDelayAfterAdd"].(string)
+		clusterAutoscaler.ScaleDownUtilizationThreshold, _ = values["scaleDownUtilizationThreshold"].(string)
+	}
+
+	return clusterAutoscaler
+}
@@ -0,0 +1,355 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/constructs-go/constructs/v10"
+)
+
+// Versions of Helm charts, EKS managed add-ons and EKS optimized AMIs that work with a Kubernetes version.
+// Before adding a version here, check it by:
+// aws eks describe-addon-versions --kubernetes-version <version> --addon-name <addon>
+// aws ssm get-parameter --name /aws/service/eks/optimized-ami/<version>/amazon-linux-2/recommended/release_version
+// aws ssm get-parameter --name /aws/service/eks/optimized-ami/<version>/amazon-linux-2023/x86_64/standard/recommended/release_version
+// aws ssm get-parameter --name /aws/service/bottlerocket/aws-k8s-<version>/x86_64/latest/image_version
+type KubernetesCompatibility struct {
+	// Helm chart versions installed by addons, keyed by chart name.
+	ChartVersions map[string]string
+	// EKS managed add-on versions allowed in 'cdk.json/context/addonVersions', keyed by add-on name.
+	AddonVersions map[string][]string
+	// AMI release versions allowed in 'cdk.json/context/amiReleaseVersion', keyed by AMI family.
+	// An AMI family without release versions is not available on the Kubernetes version.
+	// Bottlerocket release versions are OS releases shared by all aws-k8s-<version> variants,
+	// so one release is listed under every Kubernetes version it ships a variant of.
+	AmiReleaseVersions map[AmiFamilyType][]string
+	// Cluster Autoscaler image of the cluster-autoscaler chart, its minor version must match the Kubernetes minor version.
+	ClusterAutoscalerImageTag string
+}
+
+var chartVersionsBefore125 = map[string]string{
+	"metrics-server":                        "3.8.2",
+	"aws-load-balancer-controller":          "1.4.1",
+	"aws-node-termination-handler":          "0.18.0",
+	"external-dns":                          "6.2.3",
+	"aws-xray":                              "3.4.0",
+	"aws-cloudwatch-metrics":                "0.0.7",
+	"aws-for-fluent-bit":                    "0.1.15",
+	"argo-cd":                               "5.46.8",
+	"flux2":                                 "2.9.2",
+	"external-secrets":                      "0.8.5",
+	"kube-prometheus-stack":                 "45.31.1",
+	"cert-manager":                          "v1.11.5",
+	"aws-privateca-issuer":                  "v1.2.5",
+	"secrets-store-csi-driver":              "1.3.4",
+	"secrets-store-csi-driver-provider-aws": "0.3.4",
+	"aws-efs-csi-driver":                    "2.4.9",
+	"velero":                                "5.0.2",
+	"kyverno":                               "2.6.5",
+	"gatekeeper":                            "3.11.1",
+}
+
+// PodSecurityPolicy is removed since Kubernetes 1.25, charts must not create it any more.
+var chartVersionsSince125 = map[string]string{
+	"metrics-server":                        "3.12.1",
+	"aws-load-balancer-controller":          "1.8.1",
+	"aws-node-termination-handler":          "0.21.0",
+	"external-dns":                          "7.5.7",
+	"aws-xray":                              "3.4.0",
+	"aws-cloudwatch-metrics":                "0.0.11",
+	"aws-for-fluent-bit":                    "0.1.34",
+	"argo-cd":                               "7.3.11",
+	"flux2":                                 "2.13.0",
+	"external-secrets":                      "0.9.20",
+	"kube-prometheus-stack":                 "61.3.2",
+	"cert-manager":                          "v1.15.1",
+	"aws-privateca-issuer":                  "v1.3.0",
+	"secrets-store-csi-driver":              "1.4.4",
+	"secrets-store-csi-driver-provider-aws": "0.3.9",
+	"aws-efs-csi-driver":                    "3.0.7",
+	"velero":                                "7.1.1",
+	"kyverno":                               "3.2.6",
+	"gatekeeper":                            "3.16.3",
+}
+
+// Cluster Autoscaler's minor version must match the Kubernetes minor version.
+func chartVersions(base map[string]string, clusterAutoscaler string) map[string]string {
+	versions := map[string]string{
+		"cluster-autoscaler": clusterAutoscaler,
+	}
+	for chart, version := range base {
+		versions[chart] = version
+	}
+
+	return versions
+}
+
+// Control plane and nodegroups can only be upgraded one minor version at a time,
+// so every minor version between the oldest and the newest one must be listed here.
+var KubernetesCompatibilityMatrix = map[string]KubernetesCompatibility{
+	"1.21": {
+		ChartVersions:             chartVersions(chartVersionsBefore125, "9.10.9"),
+		ClusterAutoscalerImageTag: "v1.21.3",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.11.2-eksbuild.1"},
+			"kube-proxy":         {"v1.21.14-eksbuild.2"},
+			"coredns":            {"v1.8.4-eksbuild.1"},
+			"aws-ebs-csi-driver": {"v1.10.0-eksbuild.1"},
+			"adot":               {"v0.58.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2: {"1.21.14-20230217"},
+		},
+	},
+	"1.22": {
+		ChartVersions:             chartVersions(chartVersionsBefore125, "9.11.0"),
+		ClusterAutoscalerImageTag: "v1.22.3",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.11.2-eksbuild.1"},
+			"kube-proxy":         {"v1.22.11-eksbuild.2"},
+			"coredns":            {"v1.8.7-eksbuild.1"},
+			"aws-ebs-csi-driver": {"v1.10.0-eksbuild.1"},
+			"adot":               {"v0.58.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2: {"1.22.17-20230217"},
+		},
+	},
+	"1.23": {
+		ChartVersions:             chartVersions(chartVersionsBefore125, "9.13.1"),
+		ClusterAutoscalerImageTag: "v1.23.1",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.11.4-eksbuild.1"},
+			"kube-proxy":         {"v1.23.8-eksbuild.2"},
+			"coredns":            {"v1.8.7-eksbuild.2"},
+			"aws-ebs-csi-driver": {"v1.11.4-eksbuild.1"},
+			"adot":               {"v0.62.1-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.23.17-20240110"},
+			AmiFamily_AL2023:       {"1.23.17-20240110"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.24": {
+		ChartVersions:             chartVersions(chartVersionsBefore125, "9.21.0"),
+		ClusterAutoscalerImageTag: "v1.24.3",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.12.0-eksbuild.1"},
+			"kube-proxy":         {"v1.24.7-eksbuild.2"},
+			"coredns":            {"v1.8.7-eksbuild.3"},
+			"aws-ebs-csi-driver": {"v1.13.0-eksbuild.1"},
+			"adot":               {"v0.74.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.24.17-20240110"},
+			AmiFamily_AL2023:       {"1.24.17-20240110"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.25": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.24.0"),
+		ClusterAutoscalerImageTag: "v1.25.3",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.12.6-eksbuild.1"},
+			"kube-proxy":         {"v1.25.6-eksbuild.1"},
+			"coredns":            {"v1.9.3-eksbuild.2"},
+			"aws-ebs-csi-driver": {"v1.17.0-eksbuild.1"},
+			"adot":               {"v0.76.1-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.25.16-20240703"},
+			AmiFamily_AL2023:       {"1.25.16-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.26": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.26.0"),
+		ClusterAutoscalerImageTag: "v1.26.8",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.12.6-eksbuild.2"},
+			"kube-proxy":         {"v1.26.2-eksbuild.1"},
+			"coredns":            {"v1.9.3-eksbuild.3"},
+			"aws-ebs-csi-driver": {"v1.19.0-eksbuild.2"},
+			"adot":               {"v0.78.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.26.15-20240703"},
+			AmiFamily_AL2023:       {"1.26.15-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.27": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.29.0"),
+		ClusterAutoscalerImageTag: "v1.27.8",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.13.2-eksbuild.1"},
+			"kube-proxy":         {"v1.27.1-eksbuild.1"},
+			"coredns":            {"v1.10.1-eksbuild.1"},
+			"aws-ebs-csi-driver": {"v1.20.0-eksbuild.1"},
+			"adot":               {"v0.80.0-eksbuild.2"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.27.15-20240703"},
+			AmiFamily_AL2023:       {"1.27.15-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.28": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.34.0"),
+		ClusterAutoscalerImageTag: "v1.28.5",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.15.1-eksbuild.1"},
+			"kube-proxy":         {"v1.28.1-eksbuild.1"},
+			"coredns":            {"v1.10.1-eksbuild.4"},
+			"aws-ebs-csi-driver": {"v1.24.0-eksbuild.1"},
+			"adot":               {"v0.88.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.28.11-20240703"},
+			AmiFamily_AL2023:       {"1.28.11-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.29": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.35.0"),
+		ClusterAutoscalerImageTag: "v1.29.3",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.16.0-eksbuild.1"},
+			"kube-proxy":         {"v1.29.0-eksbuild.1"},
+			"coredns":            {"v1.11.1-eksbuild.4"},
+			"aws-ebs-csi-driver": {"v1.26.1-eksbuild.1"},
+			"adot":               {"v0.92.1-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.29.6-20240703"},
+			AmiFamily_AL2023:       {"1.29.6-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+	"1.30": {
+		ChartVersions:             chartVersions(chartVersionsSince125, "9.37.0"),
+		ClusterAutoscalerImageTag: "v1.30.2",
+		AddonVersions: map[string][]string{
+			"vpc-cni":            {"v1.18.1-eksbuild.3"},
+			"kube-proxy":         {"v1.30.0-eksbuild.3"},
+			"coredns":            {"v1.11.1-eksbuild.9"},
+			"aws-ebs-csi-driver": {"v1.30.0-eksbuild.1"},
+			"adot":               {"v0.98.0-eksbuild.1"},
+		},
+		AmiReleaseVersions: map[AmiFamilyType][]string{
+			AmiFamily_AL2:          {"1.30.2-20240703"},
+			AmiFamily_AL2023:       {"1.30.2-20240703"},
+			AmiFamily_BOTTLEROCKET: {"1.20.3-5d9ac849"},
+		},
+	},
+}
+
+// Minor version of a Kubernetes version, e.g. 21 for 1.21 or 1.21.14-20230217.
+func minorVersion(version string) int {
+	fields := strings.Split(version, ".")
+	if len(fields) < 2 {
+		return 0
+	}
+	minor, _ := strconv.Atoi(fields[1])
+
+	return minor
+}
+
+// Minor version of a pinned add-on version, e.g. 13 for v1.13.2-eksbuild.1. It's 0 if the add-on isn't pinned.
+func AddonMinorVersion(scope constructs.Construct, addonName string) int {
+	return minorVersion(AddonVersion(scope, addonName))
+}
+
+// Helm chart version of the cluster's Kubernetes version.
+func ChartVersion(scope constructs.Construct, chartName string) string {
+	return KubernetesCompatibilityMatrix[KubernetesVersion(scope)].ChartVersions[chartName]
+}
+
+// Cluster Autoscaler image tag of the cluster's Kubernetes version, e.g. v1.30.2.
+func ClusterAutoscalerImageTag(scope constructs.Construct) string {
+	return KubernetesCompatibilityMatrix[KubernetesVersion(scope)].ClusterAutoscalerImageTag
+}
+
+// Check the Kubernetes version, pinned add-on versions and AMI release version against the compatibility matrix.
+// It returns a message for each combination that is not allowed.
+func CheckKubernetesCompatibility(scope constructs.Construct) []string {
+	var problems []string
+
+	version := KubernetesVersion(scope)
+	compatibility, ok := KubernetesCompatibilityMatrix[version]
+	if !ok {
+		return append(problems, fmt.Sprintf("Kubernetes version %s is not in the compatibility matrix.", version))
+	}
+
+	// Control plane can only be upgraded one minor version at a time, and never downgraded.
+	deployedVersion := DeployedKubernetesVersion(scope)
+	if len(deployedVersion) > 0 {
+		step := minorVersion(version) - minorVersion(deployedVersion)
+		if step < 0 || step > 1 {
+			problems = append(problems, fmt.Sprintf(
+				"Cannot change Kubernetes version from %s to %s, upgrade one minor version at a time.", deployedVersion, version))
+		}
+	}
+
+	if minorVersion(strings.TrimPrefix(compatibility.ClusterAutoscalerImageTag, "v")) != minorVersion(version) {
+		problems = append(problems, fmt.Sprintf(
+			"Cluster Autoscaler image %s doesn't match Kubernetes %s.", compatibility.ClusterAutoscalerImageTag, version))
+	}
+
+	var addonNames []string
+	for addonName := range compatibility.AddonVersions {
+		addonNames = append(addonNames, addonName)
+	}
+	sort.Strings(addonNames)
+	for _, addonName := range addonNames {
+		allowedVersions := compatibility.AddonVersions[addonName]
+		addonVersion := AddonVersion(scope, addonName)
+		if len(addonVersion) > 0 && !contains(allowedVersions, addonVersion) {
+			problems = append(problems, fmt.Sprintf(
+				"%s add-on version %s is not allowed on Kubernetes %s, allowed versions are: %s.",
+				addonName, addonVersion, version, strings.Join(allowedVersions, ", ")))
+		}
+	}
+
+	// Custom AMIs are managed by their owners, the matrix doesn't know them.
+	if len(CustomAmiId(scope)) > 0 {
+		if len(AmiReleaseVersion(scope)) > 0 {
+			problems = append(problems, fmt.Sprintf(
+				"AMI release version %s cannot be used with custom AMI %s.", AmiReleaseVersion(scope), CustomAmiId(scope)))
+		}
+		return problems
+	}
+
+	amiFamily := AmiFamily(scope)
+	if len(compatibility.AmiReleaseVersions[amiFamily]) == 0 {
+		return append(problems, fmt.Sprintf("AMI family %s is not available on Kubernetes %s.", amiFamily, version))
+	}
+
+	// Nodegroups can stay one minor version behind the control plane while it's being upgraded.
+	// Bottlerocket release versions don't follow Kubernetes versions, so look them up in the matrix.
+	amiReleaseVersion := AmiReleaseVersion(scope)
+	if len(amiReleaseVersion) > 0 {
+		previousVersion := fmt.Sprintf("1.%d", minorVersion(version)-1)
+		if !contains(compatibility.AmiReleaseVersions[amiFamily], amiReleaseVersion) &&
+			!contains(KubernetesCompatibilityMatrix[previousVersion].AmiReleaseVersions[amiFamily], amiReleaseVersion) {
+			problems = append(problems, fmt.Sprintf(
+				"%s AMI release version %s cannot be used by nodegroups of Kubernetes %s, nodegroups must be on a release of the same or the previous minor version in the compatibility matrix.",
+				amiFamily, amiReleaseVersion, version))
+		}
+	}
+
+	return problems
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -49,25 +49,6 @@ func KeyPairName(scope constructs.Construct) string {
 	return keyPairName
 }
 
-// Master users in k8s system:masters. All users must be existing IAM Users
-// DO NOT modify this function, change master IAM users by 'cdk.json/context/masterUsers'.
-func MasterUsers(scope constructs.Construct) []string {
-	var masterUsers []string
-
-	ctxValue := scope.Node().TryGetContext(jsii.String("masterUsers"))
-	iamUsers := reflect.ValueOf(ctxValue)
-	if iamUsers.Kind() != reflect.Slice {
-		return masterUsers
-	}
-
-	for i := 0; i < iamUsers.Len(); i++ {
-		user := iamUsers.Index(i).Interface().(string)
-		masterUsers = append(masterUsers, user)
-	}
-
-	return masterUsers
-}
-
 // Deployment stage config
 type DeploymentStageType string
 
@@ -108,6 +89,386 @@ func TargetArch(scope constructs.Construct) TargetArchType {
 	return targetArch
 }
 
+// EKS cluster config
+// Allowed values are the keys of KubernetesCompatibilityMatrix.
+// DO NOT modify this function, change Kubernetes version by 'cdk.json/context/kubernetesVersion'.
+func KubernetesVersion(scope constructs.Construct) string {
+	kubernetesVersion := "1.30"
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("kubernetesVersion"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		kubernetesVersion = v
+	}
+
+	return kubernetesVersion
+}
+
+// Minor version of the cluster's Kubernetes version, e.g. 21 for 1.21.
+func KubernetesMinorVersion(scope constructs.Construct) int {
+	return minorVersion(KubernetesVersion(scope))
+}
+
+// Kubernetes version of the deployed cluster, empty if the cluster doesn't exist yet.
+// DO NOT modify this function, it's set by 'cdk-cli-wrapper-dev.sh/--context deployedKubernetesVersion='.
+func DeployedKubernetesVersion(scope constructs.Construct) string {
+	deployedKubernetesVersion := ""
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("deployedKubernetesVersion"))
+	if v, ok := ctxValue.(string); ok {
+		deployedKubernetesVersion = v
+	}
+
+	return deployedKubernetesVersion
+}
+
+// EKS optimized AMI release version of nodegroups, e.g. 1.30.2-20240703. If the value is empty, the latest one is used.
+// Change it to roll nodegroups to a new AMI, or to the new Kubernetes version after the control plane is upgraded.
+// DO NOT modify this function, change AMI release version by 'cdk.json/context/amiReleaseVersion'.
+func AmiReleaseVersion(scope constructs.Construct) string {
+	amiReleaseVersion := ""
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("amiReleaseVersion"))
+	if v, ok := ctxValue.(string); ok {
+		amiReleaseVersion = v
+	}
+
+	return amiReleaseVersion
+}
+
+// Nodegroup AMI family config
+// AL2 and AL2023 are EKS optimized Amazon Linux AMIs, BOTTLEROCKET is the container-optimized Bottlerocket OS.
+type AmiFamilyType string
+
+const (
+	AmiFamily_AL2          AmiFamilyType = "AL2"
+	AmiFamily_AL2023       AmiFamilyType = "AL2023"
+	AmiFamily_BOTTLEROCKET AmiFamilyType = "BOTTLEROCKET"
+)
+
+// DO NOT modify this function, change nodegroup's AMI family by 'cdk.json/context/amiFamily'.
+func AmiFamily(scope constructs.Construct) AmiFamilyType {
+	amiFamily := AmiFamily_AL2
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("amiFamily"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		amiFamily = AmiFamilyType(v)
+	}
+
+	return amiFamily
+}
+
+// Custom AMI of nodegroups, it must be built from the AMI family and match the target architecture.
+// Nodes of a custom AMI are bootstrapped by the user data generated in the launch templates.
+// DO NOT modify this function, change custom AMI by 'cdk.json/context/customAmiId'.
+func CustomAmiId(scope constructs.Construct) string {
+	customAmiId := ""
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("customAmiId"))
+	if v, ok := ctxValue.(string); ok {
+		customAmiId = v
+	}
+
+	return customAmiId
+}
+
+// Extra kubelet flags of nodegroups, e.g. --eviction-hard=memory.available<200Mi.
+// Bottlerocket doesn't accept kubelet flags, its kubelet is configured by TOML settings only.
+// DO NOT modify this function, change kubelet extra args by 'cdk.json/context/kubeletExtraArgs'.
+func KubeletExtraArgs(scope constructs.Construct) []string {
+	var kubeletExtraArgs []string
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("kubeletExtraArgs"))
+	args := reflect.ValueOf(ctxValue)
+	if args.Kind() != reflect.Slice {
+		return kubeletExtraArgs
+	}
+
+	for i := 0; i < args.Len(); i++ {
+		arg := args.Index(i).Interface().(string)
+		kubeletExtraArgs = append(kubeletExtraArgs, arg)
+	}
+
+	return kubeletExtraArgs
+}
+
+// Node volume config
+// Nodegroups use KMS encrypted gp3 volumes, gp3 baseline is 3000 IOPS and 125 MiB/s.
+// DO NOT modify this function, change node volume size in GiB by 'cdk.json/context/nodeVolumeSize'.
+func NodeVolumeSize(scope constructs.Construct) float64 {
+	nodeVolumeSize := 100.0
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("nodeVolumeSize"))
+	if v, ok := ctxValue.(float64); ok && v > 0 {
+		nodeVolumeSize = v
+	}
+
+	return nodeVolumeSize
+}
+
+// DO NOT modify this function, change node volume IOPS by 'cdk.json/context/nodeVolumeIops'.
+func NodeVolumeIops(scope constructs.Construct) float64 {
+	nodeVolumeIops := 3000.0
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("nodeVolumeIops"))
+	if v, ok := ctxValue.(float64); ok && v > 0 {
+		nodeVolumeIops = v
+	}
+
+	return nodeVolumeIops
+}
+
+// DO NOT modify this function, change node volume throughput in MiB/s by 'cdk.json/context/nodeVolumeThroughput'.
+func NodeVolumeThroughput(scope constructs.Construct) float64 {
+	nodeVolumeThroughput := 125.0
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("nodeVolumeThroughput"))
+	if v, ok := ctxValue.(float64); ok && v > 0 {
+		nodeVolumeThroughput = v
+	}
+
+	return nodeVolumeThroughput
+}
+
+// EC2 detailed monitoring of nodes, metrics are sent every minute instead of 5 minutes at extra cost.
+// DO NOT modify this function, change detailed monitoring by 'cdk.json/context/nodeDetailedMonitoring'.
+func NodeDetailedMonitoring(scope constructs.Construct) bool {
+	nodeDetailedMonitoring := false
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("nodeDetailedMonitoring"))
+	if v, ok := ctxValue.(bool); ok {
+		nodeDetailedMonitoring = v
+	}
+
+	return nodeDetailedMonitoring
+}
+
+// Spot nodegroup config
+// Instance types of the Spot nodegroup, they must have the same vCPUs and memory, so that Cluster Autoscaler
+// and pods' requests fit every node. More types in more pools get Spot capacity more often.
+var defaultSpotInstanceTypes = map[TargetArchType][]string{
+	TargetArch_x86: {"c5.large", "c5a.large", "c5d.large", "c6i.large", "c6a.large"},
+	TargetArch_arm: {"m6g.large", "m6gd.large", "m7g.large", "m7gd.large"},
+}
+
+// Spot nodes are tainted, only pods tolerating the taint run on them, e.g. DaemonSets of node agents.
+// Critical addons don't tolerate it, so they stay on On-Demand nodes.
+const SpotTaintKey = "spot"
+const SpotTaintValue = "true"
+
+// DO NOT modify this function, change Spot instance types by 'cdk.json/context/spotInstanceTypes'.
+// It's keyed by target architecture, e.g. {"amd64": ["c5.large", "c6i.large"], "arm64": ["m6g.large", "m7g.large"]}.
+func SpotInstanceTypes(scope constructs.Construct) []string {
+	spotInstanceTypes := defaultSpotInstanceTypes[TargetArch(scope)]
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("spotInstanceTypes"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v := stringSlice(values[string(TargetArch(scope))]); len(v) > 0 {
+			spotInstanceTypes = v
+		}
+	}
+
+	return spotInstanceTypes
+}
+
+// Envelope encryption of K8s secrets by a customer managed KMS key.
+// It can only be set when the cluster is created, CDK can't change it on an existing cluster,
+// so it's opt-in to keep clusters deployed without it updatable.
+// DO NOT modify this function, change secrets encryption by 'cdk.json/context/secretsEncryption'.
+func SecretsEncryption(scope constructs.Construct) bool {
+	secretsEncryption := false
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("secretsEncryption"))
+	if v, ok := ctxValue.(bool); ok {
+		secretsEncryption = v
+	}
+
+	return secretsEncryption
+}
+
+// Install EFS CSI driver with an EFS file system in the cluster VPC, and StorageClass efs-sc.
+// DO NOT modify this function, change EFS CSI driver by 'cdk.json/context/efsCsiDriver'.
+func EfsCsiDriver(scope constructs.Construct) bool {
+	efsCsiDriver := false
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("efsCsiDriver"))
+	if v, ok := ctxValue.(bool); ok {
+		efsCsiDriver = v
+	}
+
+	return efsCsiDriver
+}
+
+// Kubernetes service CIDR and cluster DNS IP, nodes of custom AMIs need them to bootstrap.
+// EKS picks 10.100.0.0/16 because it doesn't overlap with the VPC CIDR.
+const ServiceIpv4Cidr = "10.100.0.0/16"
+const ClusterDnsIp = "10.100.0.10"
+
+// EKS cluster endpoint access config
+// PRIVATE and public access CIDRs require private subnets, which are created in PROD stage only.
+type EndpointAccessType string
+
+const (
+	EndpointAccess_PUBLIC             EndpointAccessType = "PUBLIC"
+	EndpointAccess_PRIVATE            EndpointAccessType = "PRIVATE"
+	EndpointAccess_PUBLIC_AND_PRIVATE EndpointAccessType = "PUBLIC_AND_PRIVATE"
+)
+
+// DO NOT modify this function, change cluster endpoint access by 'cdk.json/context/endpointAccess'.
+func EndpointAccess(scope constructs.Construct) EndpointAccessType {
+	endpointAccess := EndpointAccess_PUBLIC_AND_PRIVATE
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("endpointAccess"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		endpointAccess = EndpointAccessType(v)
+	}
+
+	return endpointAccess
+}
+
+// CIDRs allowed to access the public endpoint, empty means 0.0.0.0/0.
+// DO NOT modify this function, change public endpoint allow-list by 'cdk.json/context/endpointPublicAccessCidrs'.
+func EndpointPublicAccessCidrs(scope constructs.Construct) []string {
+	var cidrs []string
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("endpointPublicAccessCidrs"))
+	values := reflect.ValueOf(ctxValue)
+	if values.Kind() != reflect.Slice {
+		return cidrs
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		cidr := values.Index(i).Interface().(string)
+		cidrs = append(cidrs, cidr)
+	}
+
+	return cidrs
+}
+
+// EKS cluster authentication mode
+// Valid values are: API_AND_CONFIG_MAP, API. It can only be changed from CONFIG_MAP to API_AND_CONFIG_MAP to API.
+// Nodegroups' roles are still mapped in aws-auth ConfigMap unless the mode is API.
+// DO NOT modify this function, change authentication mode by 'cdk.json/context/authenticationMode'.
+func AuthenticationMode(scope constructs.Construct) string {
+	authenticationMode := "API_AND_CONFIG_MAP"
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("authenticationMode"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		authenticationMode = v
+	}
+
+	return authenticationMode
+}
+
+// IAM principal granted access to the cluster by an EKS access entry.
+type AccessEntry struct {
+	// IAM principal type, user or role. It's ignored if Name is an ARN.
+	Type string
+	// IAM user name, role name or principal ARN. The principal must exist.
+	Name string
+	// Access policy, valid values are: cluster-admin, admin, edit, view.
+	Access string
+	// Namespaces the access policy is scoped to, empty means the whole cluster.
+	Namespaces []string
+}
+
+// DO NOT modify this function, change cluster access entries by 'cdk.json/context/accessEntries'.
+func AccessEntries(scope constructs.Construct) []AccessEntry {
+	var accessEntries []AccessEntry
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("accessEntries"))
+	entries, ok := ctxValue.([]interface{})
+	if !ok {
+		return accessEntries
+	}
+
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		accessEntry := AccessEntry{}
+		accessEntry.Type, _ = entry["type"].(string)
+		accessEntry.Name, _ = entry["name"].(string)
+		accessEntry.Access, _ = entry["access"].(string)
+		if namespaces, ok := entry["namespaces"].([]interface{}); ok {
+			for _, namespace := range namespaces {
+				accessEntry.Namespaces = append(accessEntry.Namespaces, namespace.(string))
+			}
+		}
+		accessEntries = append(accessEntries, accessEntry)
+	}
+
+	return accessEntries
+}
+
+// kubectl and helm used by CDK to manage K8s resources, the kubectl version must match the Kubernetes version.
+// cdk-cli-wrapper-dev.sh builds the layer by 'layers/kubectl/Makefile' before synth.
+const KubectlLayerCodePath = "layers/kubectl/build/."
+
+// Service account IAM binding config
+// IRSA binds IAM roles by the cluster's OIDC provider.
+// POD_IDENTITY binds IAM roles by EKS Pod Identity associations, it requires Kubernetes 1.24 or later.
+type ServiceAccountIdentityType string
+
+const (
+	ServiceAccountIdentity_IRSA         ServiceAccountIdentityType = "IRSA"
+	ServiceAccountIdentity_POD_IDENTITY ServiceAccountIdentityType = "POD_IDENTITY"
+)
+
+// DO NOT modify this function, change service account IAM binding type by 'cdk.json/context/serviceAccountIdentity'.
+func ServiceAccountIdentity(scope constructs.Construct) ServiceAccountIdentityType {
+	serviceAccountIdentity := ServiceAccountIdentity_IRSA
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("serviceAccountIdentity"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		serviceAccountIdentity = ServiceAccountIdentityType(v)
+	}
+
+	return serviceAccountIdentity
+}
+
+// EKS managed add-on config
+// Valid values are: NONE, OVERWRITE, PRESERVE.
+// DO NOT modify this function, change add-on conflict resolution method by 'cdk.json/context/addonResolveConflicts'.
+func AddonResolveConflicts(scope constructs.Construct) string {
+	resolveConflicts := "OVERWRITE"
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("addonResolveConflicts"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		resolveConflicts = v
+	}
+
+	return resolveConflicts
+}
+
+// Pinned versions of EKS managed add-ons, keyed by add-on name.
+// An add-on without a pinned version is resolved to the latest version compatible with the cluster at deploy time.
+// DO NOT modify this function, change add-on versions by 'cdk.json/context/addonVersions'.
+func AddonVersion(scope constructs.Construct, addonName string) string {
+	addonVersion := ""
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("addonVersions"))
+	if versions, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := versions[addonName].(string); ok {
+			addonVersion = v
+		}
+	}
+
+	return addonVersion
+}
+
+// Add-on version resolver lambda function config
+const (
+	AddonVersionFuncName       = "AddonVersionResolver"
+	AddonVersionFuncCodePath   = "functions/addon-version-resolver/."
+	AddonVersionFuncHandler    = "bootstrap"
+	AddonVersionFuncMemorySize = 128
+	AddonVersionFuncTimeout    = 60
+	AddonVersionProviderName   = "AddonVersionProvider"
+	AddonVersionResourceType   = "Custom::EKSAddonVersion"
+)
+
 // VPC config
 const vpcMask = 16
 const vpcIpv4 = "192.168.0.0"
@@ -117,6 +478,79 @@ var VpcCidr = vpcIpv4 + "/" + strconv.Itoa(vpcMask)
 const MaxAzs = 3
 const SubnetMask = vpcMask + MaxAzs
 
+// Subnet group names of the VPC, private subnets are only created in PROD stage.
+const PublicSubnetGroupName = "PublicSubnet"
+const PrivateSubnetGroupName = "PrivateSubnet"
+
+// How private subnets reach the internet in PROD stage.
+// per-az: a NAT gateway per AZ. single: one NAT gateway shared by all AZs.
+// nat-instance: one Graviton NAT instance. none+endpoints: no NAT, private subnets are isolated
+// and reach S3, DynamoDB, ECR, STS, CloudWatch Logs, EC2, EKS, EKS Auth, ELB and Auto Scaling by VPC endpoints.
+type NatModeType string
+
+const (
+	NatMode_PER_AZ         NatModeType = "per-az"
+	NatMode_SINGLE         NatModeType = "single"
+	NatMode_NAT_INSTANCE   NatModeType = "nat-instance"
+	NatMode_NONE_ENDPOINTS NatModeType = "none+endpoints"
+)
+
+const NatInstanceType = "t4g.small"
+
+// DO NOT modify this function, change NAT mode by 'cdk.json/context/natMode'.
+func NatMode(scope constructs.Construct) NatModeType {
+	natMode := NatMode_PER_AZ
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("natMode"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		natMode = NatModeType(v)
+	}
+
+	return natMode
+}
+
+// Dual-stack VPC with an Amazon-provided IPv6 CIDR, and EKS cluster of IPv6 family.
+// Pods and services get IPv6 addresses, nodes keep IPv4 addresses too.
+// It can only be set when the cluster is created, CDK can't change it on an existing cluster.
+// DO NOT modify this function, change IPv6 by 'cdk.json/context/ipv6'.
+func Ipv6(scope constructs.Construct) bool {
+	ipv6 := false
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("ipv6"))
+	if v, ok := ctxValue.(bool); ok {
+		ipv6 = v
+	}
+
+	return ipv6
+}
+
+// Secondary VPC CIDR of pods' subnets for VPC CNI custom networking, e.g. 100.64.0.0/16.
+// Pods get IPs from the subnets of their nodes' AZs instead of the nodes' subnets, empty disables custom networking.
+// DO NOT modify this function, change pod CIDR by 'cdk.json/context/podCidr'.
+func PodCidr(scope constructs.Construct) string {
+	podCidr := ""
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("podCidr"))
+	if v, ok := ctxValue.(string); ok {
+		podCidr = v
+	}
+
+	return podCidr
+}
+
+// VPC CNI assigns /28 prefixes instead of single IPs to ENIs, nodes run more pods.
+// DO NOT modify this function, change prefix delegation by 'cdk.json/context/prefixDelegation'.
+func PrefixDelegation(scope constructs.Construct) bool {
+	prefixDelegation := false
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("prefixDelegation"))
+	if v, ok := ctxValue.(bool); ok {
+		prefixDelegation = v
+	}
+
+	return prefixDelegation
+}
+
 // DO NOT modify this function, change ExternalDNS role ARN by 'cdk.json/context/externalDnsRole'.
 func ExternalDnsRole(scope constructs.Construct) string {
 	// The 'cdk.json/context/externalDnsRole' is a role defined in target account with permission policy:
@@ -171,3 +605,264 @@ func ExternalDnsRole(scope constructs.Construct) string {
 
 	return externalDnsRole
 }
+
+// ExternalDNS image of registry.k8s.io/external-dns/external-dns, it's multi-arch.
+const ExternalDnsImageTag = "v0.14.2"
+
+// DO NOT modify this function, change ExternalDNS domain filters by 'cdk.json/context/externalDnsDomainFilters'.
+// ExternalDNS only manages records of these domains, empty means all domains.
+func ExternalDnsDomainFilters(scope constructs.Construct) []string {
+	return stringSlice(scope.Node().TryGetContext(jsii.String("externalDnsDomainFilters")))
+}
+
+// Owner ID of ExternalDNS's TXT records, ExternalDNS only changes records whose TXT records have its owner ID.
+// Empty means the cluster name, so clusters sharing a hosted zone don't take over each other's records.
+// DO NOT modify this function, change ExternalDNS TXT owner ID by 'cdk.json/context/externalDnsTxtOwnerId'.
+func ExternalDnsTxtOwnerId(scope constructs.Construct) string {
+	txtOwnerId := ClusterName(scope)
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("externalDnsTxtOwnerId"))
+	if v, ok := ctxValue.(string); ok && len(v) > 0 {
+		txtOwnerId = v
+	}
+
+	return txtOwnerId
+}
+
+// DO NOT modify this function, change ExternalDNS hosted zones by 'cdk.json/context/externalDnsHostedZoneIds'.
+// ExternalDNS only manages records of these hosted zones and its IAM policy is scoped to them, empty means all hosted zones.
+func ExternalDnsHostedZoneIds(scope constructs.Construct) []string {
+	return stringSlice(scope.Node().TryGetContext(jsii.String("externalDnsHostedZoneIds")))
+}
+
+// GitOps config
+// The engine syncs workloads of the cluster from a path of a Git repository.
+type GitOpsEngineType string
+
+const (
+	GitOpsEngine_NONE   GitOpsEngineType = ""
+	GitOpsEngine_ARGOCD GitOpsEngineType = "argocd"
+	GitOpsEngine_FLUX   GitOpsEngineType = "flux"
+)
+
+type GitOpsConfig struct {
+	Engine GitOpsEngineType
+	// HTTPS URL of the Git repository.
+	RepoUrl string
+	// Path of the root Application (Argo CD) or Kustomization (Flux) in the repository.
+	Path string
+	// Branch, tag or commit to sync, Flux only accepts branches.
+	Revision string
+	// Name or ARN of a Secrets Manager secret with repository credentials: {"username": "...", "password": "..."}.
+	// Leave it empty for public repositories.
+	CredentialsSecret string
+}
+
+// DO NOT modify this function, change GitOps config by 'cdk.json/context/gitOps'.
+func GitOps(scope constructs.Construct) GitOpsConfig {
+	gitOps := GitOpsConfig{
+		Engine:   GitOpsEngine_NONE,
+		Path:     ".",
+		Revision: "main",
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("gitOps"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["engine"].(string); ok {
+			gitOps.Engine = GitOpsEngineType(v)
+		}
+		if v, ok := values["repoUrl"].(string); ok {
+			gitOps.RepoUrl = v
+		}
+		if v, ok := values["path"].(string); ok && len(v) > 0 {
+			gitOps.Path = v
+		}
+		if v, ok := values["revision"].(string); ok && len(v) > 0 {
+			gitOps.Revision = v
+		}
+		if v, ok := values["credentialsSecret"].(string); ok {
+			gitOps.CredentialsSecret = v
+		}
+	}
+
+	return gitOps
+}
+
+// Prometheus stack config
+// kube-prometheus-stack with Prometheus and Grafana on EBS volumes, Grafana is exposed by an internal ALB.
+type PrometheusStackConfig struct {
+	Enabled bool
+	// How long Prometheus keeps metrics on its EBS volume, e.g. 15d.
+	Retention string
+	// Size of Prometheus's and Grafana's EBS volumes.
+	StorageSize        string
+	GrafanaStorageSize string
+	// Remote write metrics to Amazon Managed Service for Prometheus.
+	RemoteWrite bool
+	// ID of an existing AMP workspace, a new workspace is created if it's empty.
+	WorkspaceId string
+}
+
+// DO NOT modify this function, change Prometheus stack config by 'cdk.json/context/prometheusStack'.
+func PrometheusStack(scope constructs.Construct) PrometheusStackConfig {
+	prometheusStack := PrometheusStackConfig{
+		Retention:          "15d",
+		StorageSize:        "50Gi",
+		GrafanaStorageSize: "10Gi",
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("prometheusStack"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			prometheusStack.Enabled = v
+		}
+		if v, ok := values["retention"].(string); ok && len(v) > 0 {
+			prometheusStack.Retention = v
+		}
+		if v, ok := values["storageSize"].(string); ok && len(v) > 0 {
+			prometheusStack.StorageSize = v
+		}
+		if v, ok := values["grafanaStorageSize"].(string); ok && len(v) > 0 {
+			prometheusStack.GrafanaStorageSize = v
+		}
+		if v, ok := values["remoteWrite"].(bool); ok {
+			prometheusStack.RemoteWrite = v
+		}
+		if v, ok := values["workspaceId"].(string); ok {
+			prometheusStack.WorkspaceId = v
+		}
+	}
+
+	return prometheusStack
+}
+
+// AWS Distro for OpenTelemetry config
+// ADOT collector receives traces by OTLP and X-Ray daemon protocol, and exports them to X-Ray.
+type AdotMetricsType string
+
+const (
+	AdotMetrics_NONE       AdotMetricsType = ""
+	AdotMetrics_CLOUDWATCH AdotMetricsType = "cloudwatch"
+	AdotMetrics_AMP        AdotMetricsType = "amp"
+)
+
+type AdotConfig struct {
+	Enabled bool
+	// Keep X-Ray daemon running next to the collector while its consumers move to the collector.
+	KeepXrayDaemon bool
+	// Exporter of OTLP metrics, CloudWatch EMF or Amazon Managed Service for Prometheus.
+	Metrics AdotMetricsType
+	// ID of the AMP workspace that receives metrics, required if Metrics is amp.
+	AmpWorkspaceId string
+}
+
+// DO NOT modify this function, change ADOT config by 'cdk.json/context/adot'.
+func Adot(scope constructs.Construct) AdotConfig {
+	adot := AdotConfig{
+		KeepXrayDaemon: true,
+		Metrics:        AdotMetrics_CLOUDWATCH,
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("adot"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			adot.Enabled = v
+		}
+		if v, ok := values["keepXrayDaemon"].(bool); ok {
+			adot.KeepXrayDaemon = v
+		}
+		if v, ok := values["metrics"].(string); ok {
+			adot.Metrics = AdotMetricsType(v)
+		}
+		if v, ok := values["ampWorkspaceId"].(string); ok {
+			adot.AmpWorkspaceId = v
+		}
+	}
+
+	return adot
+}
+
+// cert-manager config
+// ClusterIssuers of AWS Private CA and Let's Encrypt are created if they are configured.
+type CertManagerConfig struct {
+	Enabled bool
+	// ARN of an AWS Private CA, AWSPCAClusterIssuer aws-pca issues certificates from it.
+	PrivateCaArn string
+	// Account email of Let's Encrypt, ClusterIssuer letsencrypt solves DNS-01 challenges in ExternalDNS's hosted zones.
+	LetsEncryptEmail string
+	// Use Let's Encrypt staging server, its certificates are not trusted but its rate limits are much higher.
+	LetsEncryptStaging bool
+}
+
+// DO NOT modify this function, change cert-manager config by 'cdk.json/context/certManager'.
+func CertManager(scope constructs.Construct) CertManagerConfig {
+	certManager := CertManagerConfig{}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("certManager"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			certManager.Enabled = v
+		}
+		if v, ok := values["privateCaArn"].(string); ok {
+			certManager.PrivateCaArn = v
+		}
+		if v, ok := values["letsEncryptEmail"].(string); ok {
+			certManager.LetsEncryptEmail = v
+		}
+		if v, ok := values["letsEncryptStaging"].(bool); ok {
+			certManager.LetsEncryptStaging = v
+		}
+	}
+
+	return certManager
+}
+
+// Secrets Store CSI driver config
+// Pods mount Secrets Manager secrets and SSM parameters as files by SecretProviderClasses.
+type SecretProviderClassConfig struct {
+	Name      string
+	Namespace string
+	// K8s service account of the pods that mount the secrets, it's created with an IAM role that can only read SecretArns.
+	ServiceAccount string
+	// Complete ARNs of Secrets Manager secrets or SSM parameters, they must be in Region.
+	SecretArns []string
+	// Region of the secrets, empty means the stack's region. The AWS provider reads all secrets of a class from one region.
+	Region string
+}
+
+type SecretsStoreConfig struct {
+	Enabled               bool
+	SecretProviderClasses []SecretProviderClassConfig
+}
+
+// DO NOT modify this function, change Secrets Store CSI driver config by 'cdk.json/context/secretsStore'.
+func SecretsStore(scope constructs.Construct) SecretsStoreConfig {
+	secretsStore := SecretsStoreConfig{}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("secretsStore"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			secretsStore.Enabled = v
+		}
+		for _, c := range objectSlice(values["secretProviderClasses"]) {
+			spc := SecretProviderClassConfig{
+				SecretArns: stringSlice(c["secretArns"]),
+			}
+			if v, ok := c["name"].(string); ok && len(v) > 0 {
+				spc.Name = v
+			}
+			if v, ok := c["namespace"].(string); ok && len(v) > 0 {
+				spc.Namespace = v
+			}
+			if v, ok := c["serviceAccount"].(string); ok && len(v) > 0 {
+				spc.ServiceAccount = v
+			}
+			if v, ok := c["region"].(string); ok && len(v) > 0 {
+				spc.Region = v
+			}
+			secretsStore.SecretProviderClasses = append(secretsStore.SecretProviderClasses, spc)
+		}
+	}
+
+	return secretsStore
+}
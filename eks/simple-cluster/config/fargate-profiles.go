@@ -0,0 +1,47 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// FargateProfile runs the pods matching its namespace and labels on Fargate.
+type FargateProfile struct {
+	// Profile name, the namespace is used if it's empty.
+	Name      string
+	Namespace string
+	// Pods must have all the labels, empty means all pods in the namespace.
+	// Empty labels of kube-system only select CoreDNS and AWS Load Balancer Controller.
+	Labels map[string]string
+}
+
+// DO NOT modify this function, change Fargate profiles by 'cdk.json/context/fargateProfiles'.
+func FargateProfiles(scope constructs.Construct) []FargateProfile {
+	var profiles []FargateProfile
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("fargateProfiles"))
+	for _, value := range objectSlice(ctxValue) {
+		profile := FargateProfile{
+			Labels: stringMap(value["labels"]),
+		}
+		profile.Name, _ = value["name"].(string)
+		profile.Namespace, _ = value["namespace"].(string)
+		if len(profile.Name) == 0 {
+			profile.Name = profile.Namespace
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles
+}
+
+// Whether pods of kube-system, e.g. CoreDNS, run on Fargate.
+func KubeSystemOnFargate(scope constructs.Construct) bool {
+	for _, profile := range FargateProfiles(scope) {
+		if profile.Namespace == "kube-system" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Destinations of containers' logs shipped by Fluent Bit.
+type LogRoutingConfig struct {
+	CloudWatch CloudWatchLogDestination
+	Firehose   FirehoseLogDestination
+	OpenSearch OpenSearchLogDestination
+}
+
+type LogDestination struct {
+	Enabled bool
+	// Only logs of pods in these namespaces are routed to the destination, empty means all namespaces.
+	Namespaces []string
+}
+
+// Log group /aws/containerinsights/<clusterName>/application.
+type CloudWatchLogDestination struct {
+	LogDestination
+	RetentionDays int
+}
+
+// Firehose delivery stream to an S3 bucket, both are created by the stack.
+type FirehoseLogDestination struct {
+	LogDestination
+	// Logs are deleted from the bucket after these days, 0 means never.
+	ExpirationDays int
+}
+
+// An existing OpenSearch domain, Fluent Bit's role must be mapped to a backend role if fine-grained access control is enabled.
+type OpenSearchLogDestination struct {
+	LogDestination
+	// Domain endpoint, e.g. search-my-domain-abcdefg.ap-northeast-1.es.amazonaws.com.
+	Endpoint string
+	Index    string
+}
+
+// DO NOT modify this function, change log routing by 'cdk.json/context/logRouting'.
+func LogRouting(scope constructs.Construct) LogRoutingConfig {
+	logRouting := LogRoutingConfig{
+		CloudWatch: CloudWatchLogDestination{
+			LogDestination: LogDestination{Enabled: true},
+			RetentionDays:  1,
+		},
+		Firehose: FirehoseLogDestination{
+			ExpirationDays: 30,
+		},
+		OpenSearch: OpenSearchLogDestination{
+			Index: "eks-logs",
+		},
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("logRouting"))
+	values, ok := ctxValue.(map[string]interface{})
+	if !ok {
+		return logRouting
+	}
+
+	if v, ok := values["cloudWatch"].(map[string]interface{}); ok {
+		logRouting.CloudWatch.LogDestination = logDestination(v)
+		if days, ok := v["retentionDays"].(float64); ok {
+			logRouting.CloudWatch.RetentionDays = int(days)
+		}
+	}
+	if v, ok := values["firehose"].(map[string]interface{}); ok {
+		logRouting.Firehose.LogDestination = logDestination(v)
+		if days, ok := v["expirationDays"].(float64); ok {
+			logRouting.Firehose.ExpirationDays = int(days)
+		}
+	}
+	if v, ok := values["openSearch"].(map[string]interface{}); ok {
+		logRouting.OpenSearch.LogDestination = logDestination(v)
+		logRouting.OpenSearch.Endpoint, _ = v["endpoint"].(string)
+		if index, ok := v["index"].(string); ok && len(index) > 0 {
+			logRouting.OpenSearch.Index = index
+		}
+	}
+
+	return logRouting
+}
+
+func logDestination(value map[string]interface{}) LogDestination {
+	destination := LogDestination{
+		Namespaces: stringSlice(value["namespaces"]),
+	}
+	destination.Enabled, _ = value["enabled"].(bool)
+
+	return destination
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Policy engine config
+// Baseline policies audit violations in DEV stage and reject them in PROD stage.
+type PolicyEngineType string
+
+const (
+	PolicyEngine_KYVERNO    PolicyEngineType = "kyverno"
+	PolicyEngine_GATEKEEPER PolicyEngineType = "gatekeeper"
+)
+
+type PolicyEngineConfig struct {
+	Enabled bool
+	Engine  PolicyEngineType
+	// Image prefixes that pods may pull from, empty means ECR repositories of the stack's account and region.
+	AllowedRegistries []string
+	// Namespaces exempted from the policies, besides the system and addons' namespaces.
+	ExcludedNamespaces []string
+}
+
+// DO NOT modify this function, change policy engine config by 'cdk.json/context/policyEngine'.
+func PolicyEngine(scope constructs.Construct) PolicyEngineConfig {
+	policyEngine := PolicyEngineConfig{
+		Engine: PolicyEngine_KYVERNO,
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("policyEngine"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			policyEngine.Enabled = v
+		}
+		if v, ok := values["engine"].(string); ok && len(v) > 0 {
+			policyEngine.Engine = PolicyEngineType(v)
+		}
+		policyEngine.AllowedRegistries = stringSlice(values["allowedRegistries"])
+		policyEngine.ExcludedNamespaces = stringSlice(values["excludedNamespaces"])
+	}
+
+	return policyEngine
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Sources allowed to reach NodePorts and common app ports of nodes.
+type NodeIngressConfig struct {
+	// IPv4 or IPv6 CIDRs, e.g. the corporate network. Empty means the default of the deployment stage.
+	Cidrs []string
+	// Managed prefix lists, e.g. pl-58a04531 of CloudFront origin-facing servers.
+	PrefixListIds []string
+	// Load balancers of AWS Load Balancer Controller, they share security group LoadBalancerSG.
+	LoadBalancers bool
+}
+
+// Without cidrs of 'cdk.json/context/nodeIngress', only load balancers reach nodes in PROD stage,
+// and any IPv4 (and IPv6 if enabled) address does in DEV stage.
+// DO NOT modify this function, change node ingress by 'cdk.json/context/nodeIngress'.
+func NodeIngress(scope constructs.Construct) NodeIngressConfig {
+	nodeIngress := NodeIngressConfig{
+		LoadBalancers: true,
+	}
+	if DeploymentStage(scope) != DeploymentStage_PROD {
+		nodeIngress.Cidrs = []string{"0.0.0.0/0"}
+		if Ipv6(scope) {
+			nodeIngress.Cidrs = append(nodeIngress.Cidrs, "::/0")
+		}
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("nodeIngress"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v := stringSlice(values["cidrs"]); len(v) > 0 {
+			nodeIngress.Cidrs = v
+		}
+		nodeIngress.PrefixListIds = stringSlice(values["prefixListIds"])
+		if v, ok := values["loadBalancers"].(bool); ok {
+			nodeIngress.LoadBalancers = v
+		}
+	}
+
+	return nodeIngress
+}
+
+// SecurityGroupPolicy attaches security groups to the ENIs of matching pods.
+type SecurityGroupPolicyConfig struct {
+	Name      string
+	Namespace string
+	// Pods must have all the labels, empty means all pods in the namespace.
+	PodLabels        map[string]string
+	SecurityGroupIds []string
+}
+
+// Security groups for pods of VPC CNI.
+type PodSecurityGroupsConfig struct {
+	Enabled  bool
+	Policies []SecurityGroupPolicyConfig
+}
+
+// DO NOT modify this function, change security groups for pods by 'cdk.json/context/podSecurityGroups'.
+func PodSecurityGroups(scope constructs.Construct) PodSecurityGroupsConfig {
+	podSecurityGroups := PodSecurityGroupsConfig{}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("podSecurityGroups"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			podSecurityGroups.Enabled = v
+		}
+		for _, p := range objectSlice(values["policies"]) {
+			policy := SecurityGroupPolicyConfig{
+				PodLabels:        stringMap(p["podLabels"]),
+				SecurityGroupIds: stringSlice(p["securityGroupIds"]),
+			}
+			policy.Name, _ = p["name"].(string)
+			policy.Namespace, _ = p["namespace"].(string)
+			podSecurityGroups.Policies = append(podSecurityGroups.Policies, policy)
+		}
+	}
+
+	return podSecurityGroups
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Tenant is a team that owns a namespace of the cluster.
+type Tenant struct {
+	// Namespace of the tenant.
+	Name string
+	// ResourceQuota hard limits, e.g. {"requests.cpu": "4", "limits.memory": "16Gi", "pods": "50"}.
+	Quota map[string]string
+	// LimitRange defaults of containers without resources, e.g. {"cpu": "500m", "memory": "512Mi"}.
+	DefaultLimits   map[string]string
+	DefaultRequests map[string]string
+	// Namespaces allowed to reach pods of the tenant, pods in the tenant's namespace always can.
+	AllowedNamespaces []string
+	// CIDRs allowed to reach pods of the tenant, e.g. subnets of load balancers targeting pods' IPs.
+	// They must not contain pods' IPs, pods of any namespace in them reach the tenant.
+	AllowedCidrs []string
+	// IAM roles bound to K8s ClusterRoles in the tenant's namespace.
+	Roles []TenantRole
+	// Service accounts with IAM roles in the tenant's namespace.
+	ServiceAccounts []TenantServiceAccount
+}
+
+type TenantRole struct {
+	// IAM role name or ARN. The role must exist and must not be in 'cdk.json/context/accessEntries'.
+	Name string
+	// K8s ClusterRole bound in the namespace, valid values are: admin, edit, view.
+	Access string
+}
+
+type TenantServiceAccount struct {
+	Name string
+	// AWS managed policy names or managed policy ARNs attached to the service account's role.
+	ManagedPolicies []string
+}
+
+// DO NOT modify this function, change tenants by 'cdk.json/context/tenants'.
+func Tenants(scope constructs.Construct) []Tenant {
+	var tenants []Tenant
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("tenants"))
+	values, ok := ctxValue.([]interface{})
+	if !ok {
+		return tenants
+	}
+
+	for _, v := range values {
+		value, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		tenant := Tenant{
+			Quota:             stringMap(value["quota"]),
+			DefaultLimits:     stringMap(value["defaultLimits"]),
+			DefaultRequests:   stringMap(value["defaultRequests"]),
+			AllowedNamespaces: stringSlice(value["allowedNamespaces"]),
+			AllowedCidrs:      stringSlice(value["allowedCidrs"]),
+		}
+		tenant.Name, _ = value["name"].(string)
+
+		for _, r := range objectSlice(value["roles"]) {
+			role := TenantRole{}
+			role.Name, _ = r["name"].(string)
+			role.Access, _ = r["access"].(string)
+			tenant.Roles = append(tenant.Roles, role)
+		}
+		for _, s := range objectSlice(value["serviceAccounts"]) {
+			sa := TenantServiceAccount{
+				ManagedPolicies: stringSlice(s["managedPolicies"]),
+			}
+			sa.Name, _ = s["name"].(string)
+			tenant.ServiceAccounts = append(tenant.ServiceAccounts, sa)
+		}
+
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants
+}
+
+func stringMap(value interface{}) map[string]string {
+	result := map[string]string{}
+	if m, ok := value.(map[string]interface{}); ok {
+		for k, v := range m {
+			if s, ok := v.(string); ok {
+				result[k] = s
+			}
+		}
+	}
+
+	return result
+}
+
+func stringSlice(value interface{}) []string {
+	var result []string
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+
+	return result
+}
+
+func objectSlice(value interface{}) []map[string]interface{} {
+	var result []map[string]interface{}
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if m, ok := v.(map[string]interface{}); ok {
+				result = append(result, m)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// velero-plugin-for-aws images, keyed by the velero chart version, the plugin must match the chart's Velero version.
+var VeleroAwsPluginImageTags = map[string]string{
+	"5.0.2": "v1.7.1",
+	"7.1.1": "v1.10.0",
+}
+
+// Velero backs up K8s resources to an S3 bucket, and volumes by EBS snapshots or file system backup.
+type VeleroConfig struct {
+	Enabled bool
+	// Cron expression of the default backup schedule, in UTC.
+	Schedule string
+	// How long Velero keeps a backup, e.g. 720h.
+	Ttl string
+	// Only these namespaces are backed up, empty means all namespaces.
+	IncludedNamespaces []string
+	// Objects are deleted from the bucket after these days, it must be longer than Ttl.
+	ExpirationDays int
+	// Back up volumes by file system backup of node agents instead of EBS snapshots, EBS snapshots can't leave the region.
+	FsBackup bool
+	// The bucket is replicated to a bucket in this region, empty disables replication.
+	ReplicaRegion string
+	// An existing bucket in the stack's region, e.g. the replica bucket of another cluster,
+	// it's added as read-only backup location 'restore'.
+	RestoreBucket string
+}
+
+// DO NOT modify this function, change Velero config by 'cdk.json/context/velero'.
+func Velero(scope constructs.Construct) VeleroConfig {
+	velero := VeleroConfig{
+		Schedule:       "0 3 * * *",
+		Ttl:            "720h",
+		ExpirationDays: 90,
+	}
+
+	ctxValue := scope.Node().TryGetContext(jsii.String("velero"))
+	if values, ok := ctxValue.(map[string]interface{}); ok {
+		if v, ok := values["enabled"].(bool); ok {
+			velero.Enabled = v
+		}
+		if v, ok := values["schedule"].(string); ok && len(v) > 0 {
+			velero.Schedule = v
+		}
+		if v, ok := values["ttl"].(string); ok && len(v) > 0 {
+			velero.Ttl = v
+		}
+		velero.IncludedNamespaces = stringSlice(values["includedNamespaces"])
+		if v, ok := values["expirationDays"].(float64); ok {
+			velero.ExpirationDays = int(v)
+		}
+		if v, ok := values["fsBackup"].(bool); ok {
+			velero.FsBackup = v
+		}
+		velero.ReplicaRegion, _ = values["replicaRegion"].(string)
+		velero.RestoreBucket, _ = values["restoreBucket"].(string)
+	}
+
+	return velero
+}
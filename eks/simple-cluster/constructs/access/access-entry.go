@@ -0,0 +1,121 @@
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// EKS access policies of 'cdk.json/context/accessEntries/access'.
+// cluster-admin is the same as K8s system:masters group, the others are the same as K8s default user-facing roles.
+var accessPolicyNames = map[string]string{
+	"cluster-admin": "AmazonEKSClusterAdminPolicy",
+	"admin":         "AmazonEKSAdminPolicy",
+	"edit":          "AmazonEKSEditPolicy",
+	"view":          "AmazonEKSViewPolicy",
+}
+
+// Grant IAM users and roles access to the cluster by EKS access entries and access policies.
+// Access entries replace the mappings in aws-auth ConfigMap, they require authentication mode API_AND_CONFIG_MAP or API.
+// A principal can only have one access entry, it must be listed once and must not be a tenant's role.
+func NewEksAccessEntries(stack awscdk.Stack, cluster awseks.Cluster) {
+	tenantRoles := map[string]string{}
+	for _, tenant := range config.Tenants(stack) {
+		for _, role := range tenant.Roles {
+			tenantRoles[PrincipalKey("role", role.Name)] = tenant.Name
+		}
+	}
+
+	principals := map[string]bool{}
+	for _, entry := range config.AccessEntries(stack) {
+		principalKey := PrincipalKey(entry.Type, entry.Name)
+		if principals[principalKey] {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Principal %s is listed in accessEntries more than once, a principal can only have one access entry.", entry.Name)))
+			continue
+		}
+		principals[principalKey] = true
+		if tenant, ok := tenantRoles[principalKey]; ok {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Principal %s is a role of tenant %s, it must not be in accessEntries.", entry.Name, tenant)))
+			continue
+		}
+
+		policyName, ok := accessPolicyNames[entry.Access]
+		if !ok {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Access %s of %s is not valid, valid values are: cluster-admin, admin, edit, view.", entry.Access, entry.Name)))
+			continue
+		}
+
+		scopeType := awseks.AccessScopeType_CLUSTER
+		var namespaces *[]*string = nil
+		if len(entry.Namespaces) > 0 {
+			if entry.Access == "cluster-admin" {
+				awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+					"Access cluster-admin of %s cannot be scoped to namespaces, use admin instead.", entry.Name)))
+				continue
+			}
+			scopeType = awseks.AccessScopeType_NAMESPACE
+			namespaces = jsii.Strings(entry.Namespaces...)
+		}
+
+		principalArn := PrincipalArn(stack, entry.Type, entry.Name)
+		if principalArn == nil {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Principal type %s of %s is not valid, valid values are: user, role.", entry.Type, entry.Name)))
+			continue
+		}
+
+		cluster.GrantAccess(jsii.String("ClusterAccess-"+PrincipalId(entry.Type, entry.Name)), principalArn, &[]awseks.IAccessPolicy{
+			awseks.AccessPolicy_FromAccessPolicyName(jsii.String(policyName), &awseks.AccessPolicyNameOptions{
+				AccessScopeType: scopeType,
+				Namespaces:      namespaces,
+			}),
+		})
+	}
+}
+
+// ARN of an IAM user or role. The name can be an ARN, then it's returned as is.
+// It returns nil if the principal type is neither user nor role.
+func PrincipalArn(stack awscdk.Stack, principalType string, name string) *string {
+	if strings.HasPrefix(name, "arn:") {
+		return jsii.String(name)
+	}
+	if principalType != "user" && principalType != "role" {
+		return nil
+	}
+
+	return stack.FormatArn(&awscdk.ArnComponents{
+		Service:      jsii.String("iam"),
+		Region:       jsii.String(""),
+		Resource:     jsii.String(principalType),
+		ResourceName: jsii.String(name),
+	})
+}
+
+// Key of an IAM user or role that's the same for its name and ARN, e.g. role/DevTeam.
+// IAM names are unique regardless of their paths, so the path of an ARN is dropped.
+func PrincipalKey(principalType string, name string) string {
+	if strings.HasPrefix(name, "arn:") {
+		resource := name[strings.LastIndex(name, ":")+1:]
+		fields := strings.Split(resource, "/")
+		return fields[0] + "/" + fields[len(fields)-1]
+	}
+
+	return principalType + "/" + name
+}
+
+// Construct id suffix of an IAM user or role, e.g. user-Cow or role-DevTeam.
+func PrincipalId(principalType string, name string) string {
+	if strings.HasPrefix(name, "arn:") {
+		return strings.NewReplacer(":", "-", "/", "-").Replace(name[strings.LastIndex(name, ":")+1:])
+	}
+
+	return principalType + "-" + name
+}
@@ -0,0 +1,165 @@
+package addons
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install AWS Distro for OpenTelemetry (ADOT) add-on and a collector.
+// The collector receives traces by OTLP and X-Ray daemon protocol (UDP 2000) and exports them to X-Ray,
+// X-Ray daemon consumers switch to it by AWS_XRAY_DAEMON_ADDRESS=adot-collector.opentelemetry:2000.
+// OTLP metrics are exported to CloudWatch (EMF) or Amazon Managed Service for Prometheus.
+func NewEksAdot(stack awscdk.Stack, cluster awseks.Cluster) {
+	adot := config.Adot(stack)
+	namespace := "opentelemetry"
+
+	// ADOT operator's admission webhooks require cert-manager.
+	certManagerChart, _ := newEksCertManager(stack, cluster)
+	adotAddon := newManagedAddon(stack, cluster, &managedAddonProps{
+		Id:        "ADOT",
+		AddonName: "adot",
+	})
+	adotAddon.Node().AddDependency(certManagerChart)
+
+	otelNamespace := cluster.AddManifest(jsii.String("OpenTelemetryNamespace"), &map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": namespace,
+		},
+	})
+
+	collectorSa := newServiceAccount(stack, cluster, "ADOTCollectorSA", namespace, "adot-collector")
+	collectorSa.Node().AddDependency(otelNamespace)
+	collectorSa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AWSXrayWriteOnlyAccess")))
+
+	receivers := map[string]interface{}{
+		"otlp": map[string]interface{}{
+			"protocols": map[string]interface{}{
+				"grpc": map[string]string{"endpoint": "0.0.0.0:4317"},
+				"http": map[string]string{"endpoint": "0.0.0.0:4318"},
+			},
+		},
+		"awsxray": map[string]interface{}{
+			"endpoint":  "0.0.0.0:2000",
+			"transport": "udp",
+		},
+	}
+	processors := map[string]interface{}{
+		"batch/traces": map[string]interface{}{
+			"timeout":         "1s",
+			"send_batch_size": 50,
+		},
+		"batch/metrics": map[string]interface{}{
+			"timeout": "60s",
+		},
+	}
+	exporters := map[string]interface{}{
+		"awsxray": map[string]interface{}{
+			"region": *stack.Region(),
+		},
+	}
+	extensions := map[string]interface{}{
+		"health_check": map[string]interface{}{},
+	}
+	pipelines := map[string]interface{}{
+		"traces": map[string]interface{}{
+			"receivers":  []string{"otlp", "awsxray"},
+			"processors": []string{"batch/traces"},
+			"exporters":  []string{"awsxray"},
+		},
+	}
+
+	var metricsExporter string
+	switch adot.Metrics {
+	case config.AdotMetrics_NONE:
+	case config.AdotMetrics_CLOUDWATCH:
+		metricsExporter = "awsemf"
+		collectorSa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("CloudWatchAgentServerPolicy")))
+		exporters[metricsExporter] = map[string]interface{}{
+			"region":         *stack.Region(),
+			"namespace":      "EKS/OpenTelemetry",
+			"log_group_name": "/aws/eks/" + *cluster.ClusterName() + "/otel-metrics",
+		}
+	case config.AdotMetrics_AMP:
+		if len(adot.AmpWorkspaceId) == 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("ADOT metrics amp requires an AMP workspace, set adot/ampWorkspaceId."))
+			break
+		}
+		metricsExporter = "prometheusremotewrite"
+		workspaceArn, remoteWriteUrl := ampWorkspace(stack, adot.AmpWorkspaceId)
+		collectorSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("aps:RemoteWrite"),
+			},
+			Resources: &[]*string{
+				workspaceArn,
+			},
+		}))
+		extensions["sigv4auth"] = map[string]interface{}{
+			"region":  *stack.Region(),
+			"service": "aps",
+		}
+		exporters[metricsExporter] = map[string]interface{}{
+			"endpoint": *remoteWriteUrl,
+			"auth": map[string]string{
+				"authenticator": "sigv4auth",
+			},
+		}
+	default:
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"ADOT metrics %s is not valid, valid values are: cloudwatch, amp or empty.", adot.Metrics)))
+	}
+	if len(metricsExporter) > 0 {
+		pipelines["metrics"] = map[string]interface{}{
+			"receivers":  []string{"otlp"},
+			"processors": []string{"batch/metrics"},
+			"exporters":  []string{metricsExporter},
+		}
+	}
+
+	var extensionNames []string
+	for name := range extensions {
+		extensionNames = append(extensionNames, name)
+	}
+	sort.Strings(extensionNames)
+	// Collector config is YAML, JSON is valid YAML.
+	collectorConfig, err := json.Marshal(map[string]interface{}{
+		"receivers":  receivers,
+		"processors": processors,
+		"exporters":  exporters,
+		"extensions": extensions,
+		"service": map[string]interface{}{
+			"extensions": extensionNames,
+			"pipelines":  pipelines,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	collector := cluster.AddManifest(jsii.String("ADOTCollector"), &map[string]interface{}{
+		"apiVersion": "opentelemetry.io/v1alpha1",
+		"kind":       "OpenTelemetryCollector",
+		"metadata": map[string]interface{}{
+			"name":      "adot",
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"mode":           "deployment",
+			"serviceAccount": *collectorSa.ServiceAccountName(),
+			"config":         string(collectorConfig),
+		},
+	})
+	collector.Node().AddDependency(adotAddon)
+	collector.Node().AddDependency(collectorSa)
+}
@@ -1,6 +1,8 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
@@ -9,11 +11,7 @@ import (
 
 // Install AWS X-Ray daemon
 func NewEksAwsXray(stack awscdk.Stack, cluster awseks.Cluster) {
-	xraySa := awseks.NewServiceAccount(stack, jsii.String("AWSXRaySA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("aws-xray"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
+	xraySa := newServiceAccount(stack, cluster, "AWSXRaySA", "kube-system", "aws-xray")
 	xraySa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AWSXRayDaemonWriteAccess")))
 
 	// https://artifacthub.io/packages/helm/okgolove/aws-xray
@@ -24,7 +22,7 @@ func NewEksAwsXray(stack awscdk.Stack, cluster awseks.Cluster) {
 		Chart:      jsii.String("aws-xray"),
 		Namespace:  jsii.String("kube-system"),
 		Wait:       jsii.Bool(true),
-		Version:    jsii.String("3.4.0"),
+		Version:    jsii.String(config.ChartVersion(stack, "aws-xray")),
 		Values: &map[string]interface{}{
 			"serviceAccount": map[string]interface{}{
 				"create": jsii.Bool(false),
@@ -33,6 +31,7 @@ func NewEksAwsXray(stack awscdk.Stack, cluster awseks.Cluster) {
 			"xray": map[string]interface{}{
 				"region": stack.Region(),
 			},
+			"tolerations": spotTolerations,
 			"resources": map[string]map[string]interface{}{
 				"requests": {
 					"cpu":    jsii.String("256m"),
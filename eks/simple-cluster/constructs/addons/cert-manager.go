@@ -0,0 +1,220 @@
+package addons
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install cert-manager with ClusterIssuers of 'cdk.json/context/certManager'.
+// AWSPCAClusterIssuer aws-pca issues private certificates from AWS Private CA,
+// ClusterIssuer letsencrypt issues public certificates by solving DNS-01 challenges in Route 53.
+func NewEksCertManager(stack awscdk.Stack, cluster awseks.Cluster) {
+	certManager := config.CertManager(stack)
+	certManagerChart, certManagerSa := newEksCertManager(stack, cluster)
+
+	if len(certManager.PrivateCaArn) > 0 {
+		newEksPrivateCaIssuer(stack, cluster, certManagerChart, certManager.PrivateCaArn)
+	}
+
+	if len(certManager.LetsEncryptEmail) > 0 {
+		newEksLetsEncryptIssuer(stack, cluster, certManagerChart, certManagerSa, certManager)
+	}
+}
+
+// Install cert-manager, it's shared by the addons that need certificates, e.g. ADOT's admission webhooks.
+func newEksCertManager(stack awscdk.Stack, cluster awseks.Cluster) (awseks.HelmChart, awseks.ServiceAccount) {
+	if chart, ok := stack.Node().TryFindChild(jsii.String("CertManagerChart")).(awseks.HelmChart); ok {
+		return chart, stack.Node().FindChild(jsii.String("CertManagerSA")).(awseks.ServiceAccount)
+	}
+
+	certManagerNamespace := cluster.AddManifest(jsii.String("CertManagerNamespace"), &map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": "cert-manager",
+		},
+	})
+
+	// The role is used by DNS-01 challenge solvers.
+	certManagerSa := newServiceAccount(stack, cluster, "CertManagerSA", "cert-manager", "cert-manager")
+	certManagerSa.Node().AddDependency(certManagerNamespace)
+
+	// https://github.com/cert-manager/cert-manager/tree/master/deploy/charts/cert-manager
+	certManagerChart := awseks.NewHelmChart(stack, jsii.String("CertManagerChart"), &awseks.HelmChartProps{
+		Repository:      jsii.String("https://charts.jetstack.io"),
+		Release:         jsii.String("cert-manager"),
+		Cluster:         cluster,
+		Chart:           jsii.String("cert-manager"),
+		Namespace:       jsii.String("cert-manager"),
+		CreateNamespace: jsii.Bool(true),
+		Wait:            jsii.Bool(true),
+		Version:         jsii.String(config.ChartVersion(stack, "cert-manager")),
+		Values: &map[string]interface{}{
+			"installCRDs": jsii.Bool(true),
+			"serviceAccount": map[string]interface{}{
+				"create": jsii.Bool(false),
+				"name":   certManagerSa.ServiceAccountName(),
+			},
+			// The controller runs as non-root, it must be able to read the projected web identity token.
+			"securityContext": map[string]interface{}{
+				"fsGroup": jsii.Number(1001),
+			},
+		},
+	})
+	certManagerChart.Node().AddDependency(certManagerSa)
+
+	return certManagerChart, certManagerSa
+}
+
+// Install AWS Private CA issuer and AWSPCAClusterIssuer aws-pca, its role can only issue certificates from the CA.
+func newEksPrivateCaIssuer(stack awscdk.Stack, cluster awseks.Cluster, certManagerChart awseks.HelmChart, privateCaArn string) {
+	// arn:<partition>:acm-pca:<region>:<account>:certificate-authority/<id>
+	arnParts := strings.Split(privateCaArn, ":")
+	if len(arnParts) != 6 || arnParts[2] != "acm-pca" {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Private CA ARN %s is not valid, set certManager/privateCaArn to the ARN of an AWS Private CA.", privateCaArn)))
+		return
+	}
+
+	pcaIssuerSa := newServiceAccount(stack, cluster, "AWSPCAIssuerSA", "cert-manager", "aws-privateca-issuer")
+	pcaIssuerSa.Node().AddDependency(certManagerChart)
+	pcaIssuerSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("acm-pca:DescribeCertificateAuthority"),
+			jsii.String("acm-pca:GetCertificate"),
+			jsii.String("acm-pca:IssueCertificate"),
+		},
+		Resources: &[]*string{
+			jsii.String(privateCaArn),
+		},
+	}))
+
+	// https://github.com/cert-manager/aws-privateca-issuer/tree/main/charts/aws-pca-issuer
+	pcaIssuerChart := awseks.NewHelmChart(stack, jsii.String("AWSPCAIssuerChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://cert-manager.github.io/aws-privateca-issuer"),
+		Release:    jsii.String("aws-privateca-issuer"),
+		Cluster:    cluster,
+		Chart:      jsii.String("aws-privateca-issuer"),
+		Namespace:  jsii.String("cert-manager"),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(config.ChartVersion(stack, "aws-privateca-issuer")),
+		Values: &map[string]interface{}{
+			"serviceAccount": map[string]interface{}{
+				"create": jsii.Bool(false),
+				"name":   pcaIssuerSa.ServiceAccountName(),
+			},
+		},
+	})
+	pcaIssuerChart.Node().AddDependency(pcaIssuerSa)
+
+	pcaIssuer := cluster.AddManifest(jsii.String("AWSPCAClusterIssuer"), &map[string]interface{}{
+		"apiVersion": "awspca.cert-manager.io/v1beta1",
+		"kind":       "AWSPCAClusterIssuer",
+		"metadata": map[string]interface{}{
+			"name": "aws-pca",
+		},
+		"spec": map[string]interface{}{
+			"arn":    privateCaArn,
+			"region": arnParts[3],
+		},
+	})
+	pcaIssuer.Node().AddDependency(pcaIssuerChart)
+}
+
+// Create ClusterIssuer letsencrypt, it solves DNS-01 challenges in the hosted zones of ExternalDNS.
+// With 'cdk.json/context/externalDnsRole', the solver assumes that role to change records in the other account.
+func newEksLetsEncryptIssuer(stack awscdk.Stack, cluster awseks.Cluster, certManagerChart awseks.HelmChart, certManagerSa awseks.ServiceAccount, certManager config.CertManagerConfig) {
+	route53 := map[string]interface{}{
+		"region": *stack.Region(),
+	}
+
+	if externalDnsRole := config.ExternalDnsRole(stack); len(externalDnsRole) > 0 {
+		route53["role"] = externalDnsRole
+		certManagerSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("sts:AssumeRole"),
+			},
+			Resources: &[]*string{
+				jsii.String(externalDnsRole),
+			},
+		}))
+	} else {
+		hostedZoneArns := externalDnsHostedZoneArns(stack)
+		certManagerSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("route53:ChangeResourceRecordSets"),
+				jsii.String("route53:ListResourceRecordSets"),
+			},
+			Resources: &hostedZoneArns,
+		}))
+		certManagerSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("route53:GetChange"),
+			},
+			Resources: &[]*string{
+				stack.FormatArn(&awscdk.ArnComponents{
+					Service:      jsii.String("route53"),
+					Region:       jsii.String(""),
+					Account:      jsii.String(""),
+					Resource:     jsii.String("change"),
+					ResourceName: jsii.String("*"),
+				}),
+			},
+		}))
+		certManagerSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("route53:ListHostedZonesByName"),
+			},
+			Resources: &[]*string{
+				jsii.String("*"),
+			},
+		}))
+	}
+
+	solver := map[string]interface{}{
+		"dns01": map[string]interface{}{
+			"route53": route53,
+		},
+	}
+	if domainFilters := config.ExternalDnsDomainFilters(stack); len(domainFilters) > 0 {
+		solver["selector"] = map[string]interface{}{
+			"dnsZones": domainFilters,
+		}
+	}
+
+	server := "https://acme-v02.api.letsencrypt.org/directory"
+	if certManager.LetsEncryptStaging {
+		server = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	}
+
+	letsEncryptIssuer := cluster.AddManifest(jsii.String("LetsEncryptClusterIssuer"), &map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "ClusterIssuer",
+		"metadata": map[string]interface{}{
+			"name": "letsencrypt",
+		},
+		"spec": map[string]interface{}{
+			"acme": map[string]interface{}{
+				"server": server,
+				"email":  certManager.LetsEncryptEmail,
+				"privateKeySecretRef": map[string]string{
+					"name": "letsencrypt-account-key",
+				},
+				"solvers": []interface{}{solver},
+			},
+		},
+	})
+	letsEncryptIssuer.Node().AddDependency(certManagerChart)
+}
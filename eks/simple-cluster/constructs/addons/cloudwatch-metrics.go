@@ -1,6 +1,8 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
@@ -9,11 +11,7 @@ import (
 
 // Install aws-cloudwatch-metrics, a CloudWatch Agent to Collect Cluster Metrics.
 func NewEksCloudWatchMetrics(stack awscdk.Stack, cluster awseks.Cluster) {
-	cwAgentSa := awseks.NewServiceAccount(stack, jsii.String("AWSCloudWatchAgentSA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("cloudwatch-agent"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
+	cwAgentSa := newServiceAccount(stack, cluster, "AWSCloudWatchAgentSA", "kube-system", "cloudwatch-agent")
 	cwAgentSa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("CloudWatchAgentServerPolicy")))
 
 	// https://github.com/aws/eks-charts/tree/master/stable/aws-cloudwatch-metrics
@@ -25,13 +23,14 @@ func NewEksCloudWatchMetrics(stack awscdk.Stack, cluster awseks.Cluster) {
 		Namespace:       jsii.String("kube-system"),
 		CreateNamespace: jsii.Bool(true),
 		Wait:            jsii.Bool(true),
-		Version:         jsii.String("0.0.7"),
+		Version:         jsii.String(config.ChartVersion(stack, "aws-cloudwatch-metrics")),
 		Values: &map[string]interface{}{
 			"clusterName": cluster.ClusterName(),
 			"serviceAccount": map[string]interface{}{
 				"create": jsii.Bool(false),
 				"name":   cwAgentSa.ServiceAccountName(),
 			},
+			"tolerations": spotTolerations,
 		},
 	})
 }
@@ -1,14 +1,43 @@
 package addons
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/jsii-runtime-go"
 )
 
-// Install Cluster Autoscaler
-func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster) {
+// Nodegroup that Cluster Autoscaler scales, as it's defined by the stack.
+type AutoscaledNodegroup struct {
+	Name         string
+	CapacityType awseks.CapacityType
+	MinSize      int
+	MaxSize      int
+}
+
+// Spot nodegroups are cheaper, they're preferred by the priority expander.
+// Pods that don't tolerate the Spot taint only fit On-Demand nodegroups anyway.
+var autoscalerPriorities = map[awseks.CapacityType]int{
+	awseks.CapacityType_SPOT:      50,
+	awseks.CapacityType_ON_DEMAND: 10,
+}
+
+// Install Cluster Autoscaler, configured from the nodegroups and 'cdk.json/context/clusterAutoscaler'.
+// Its IAM policy can only scale Auto Scaling groups tagged with this cluster, which EKS tags managed nodegroups' groups with.
+func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster, nodegroups []AutoscaledNodegroup) {
+	clusterAutoscaler := config.ClusterAutoscaler(stack)
+	clusterName := config.ClusterName(stack)
+	discoveryTags := []string{
+		"k8s.io/cluster-autoscaler/enabled",
+		"k8s.io/cluster-autoscaler/" + clusterName,
+	}
+
 	// Create IAM Policy for Cluster Autoscaler
 	caPolicy := awsiam.NewPolicyDocument(&awsiam.PolicyDocumentProps{
 		AssignSids: jsii.Bool(true),
@@ -19,23 +48,51 @@ func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster) {
 					jsii.String("autoscaling:DescribeAutoScalingGroups"),
 					jsii.String("autoscaling:DescribeAutoScalingInstances"),
 					jsii.String("autoscaling:DescribeLaunchConfigurations"),
+					jsii.String("autoscaling:DescribeScalingActivities"),
 					jsii.String("autoscaling:DescribeTags"),
+					jsii.String("ec2:DescribeImages"),
+					jsii.String("ec2:DescribeInstanceTypes"),
+					jsii.String("ec2:DescribeLaunchTemplateVersions"),
+					jsii.String("ec2:GetInstanceTypesFromInstanceRequirements"),
+				},
+				Resources: &[]*string{
+					jsii.String("*"),
+				},
+			}),
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Effect: awsiam.Effect_ALLOW,
+				Actions: &[]*string{
 					jsii.String("autoscaling:SetDesiredCapacity"),
 					jsii.String("autoscaling:TerminateInstanceInAutoScalingGroup"),
-					jsii.String("ec2:DescribeLaunchTemplateVersions"),
 				},
 				Resources: &[]*string{
 					jsii.String("*"),
 				},
+				Conditions: &map[string]interface{}{
+					"StringEquals": map[string]string{
+						"aws:ResourceTag/" + discoveryTags[0]: "true",
+						"aws:ResourceTag/" + discoveryTags[1]: "owned",
+					},
+				},
+			}),
+			// Labels and taints of managed nodegroups let Cluster Autoscaler scale them from zero.
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Effect: awsiam.Effect_ALLOW,
+				Actions: &[]*string{
+					jsii.String("eks:DescribeNodegroup"),
+				},
+				Resources: &[]*string{
+					stack.FormatArn(&awscdk.ArnComponents{
+						Service:      jsii.String("eks"),
+						Resource:     jsii.String("nodegroup"),
+						ResourceName: jsii.String(clusterName + "/*"),
+					}),
+				},
 			}),
 		},
 	})
 
-	caSa := awseks.NewServiceAccount(stack, jsii.String("ClusterAutoscalerSA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("cluster-autoscaler-sa"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
+	caSa := newServiceAccount(stack, cluster, "ClusterAutoscalerSA", "kube-system", "cluster-autoscaler-sa")
 
 	awsiam.NewPolicy(stack, jsii.String("ClusterAutoscalerPolicy"), &awsiam.PolicyProps{
 		Document:   caPolicy,
@@ -45,6 +102,45 @@ func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster) {
 		},
 	})
 
+	extraArgs := map[string]interface{}{
+		"skip-nodes-with-system-pods":   jsii.Bool(false),
+		"skip-nodes-with-local-storage": jsii.Bool(false),
+		"balance-similar-node-groups":   jsii.Bool(true),
+	}
+	for arg, value := range autoscalerScaleDownArgs(stack, clusterAutoscaler) {
+		extraArgs[arg] = jsii.String(value)
+	}
+
+	// Cluster Autoscaler never scales the nodegroups beyond their max sizes in total.
+	maxNodesTotal := 0
+	hasSpot := false
+	for _, nodegroup := range nodegroups {
+		maxNodesTotal += nodegroup.MaxSize
+		hasSpot = hasSpot || nodegroup.CapacityType == awseks.CapacityType_SPOT
+	}
+	extraArgs["max-nodes-total"] = jsii.Number(maxNodesTotal)
+	// Unfulfilled Spot requests fall back to other nodegroups sooner than the default 15 minutes.
+	if hasSpot {
+		extraArgs["max-node-provision-time"] = jsii.String("5m")
+	}
+
+	var priorityConfigMap awseks.KubernetesManifest = nil
+	switch clusterAutoscaler.Expander {
+	case config.ClusterAutoscalerExpander_PRIORITY:
+		// Multiple expanders are supported since Cluster Autoscaler 1.23.
+		if config.KubernetesMinorVersion(stack) >= 23 {
+			extraArgs["expander"] = jsii.String("priority,least-waste")
+		} else {
+			extraArgs["expander"] = jsii.String("priority")
+		}
+		priorityConfigMap = newAutoscalerPriorityConfigMap(cluster, nodegroups)
+	case config.ClusterAutoscalerExpander_LEAST_WASTE:
+		extraArgs["expander"] = jsii.String("least-waste")
+	default:
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Cluster Autoscaler expander %s is not valid, valid values are: priority, least-waste.", clusterAutoscaler.Expander)))
+	}
+
 	// https://github.com/kubernetes/autoscaler/tree/master/charts/cluster-autoscaler
 	caChart := awseks.NewHelmChart(stack, jsii.String("ClusterAutoscalerChart"), &awseks.HelmChartProps{
 		Repository: jsii.String("https://kubernetes.github.io/autoscaler"),
@@ -53,12 +149,17 @@ func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster) {
 		Chart:      jsii.String("cluster-autoscaler"),
 		Namespace:  jsii.String("kube-system"),
 		Wait:       jsii.Bool(true),
-		Version:    jsii.String("9.13.1"),
+		Version:    jsii.String(config.ChartVersion(stack, "cluster-autoscaler")),
 		Values: &map[string]interface{}{
 			"cloudProvider": jsii.String("aws"),
 			"awsRegion":     jsii.String(*stack.Region()),
-			"autoDiscovery": map[string]string{
+			"autoDiscovery": map[string]interface{}{
 				"clusterName": *cluster.ClusterName(),
+				"tags":        discoveryTags,
+			},
+			// Cluster Autoscaler's minor version must match the Kubernetes minor version.
+			"image": map[string]interface{}{
+				"tag": config.ClusterAutoscalerImageTag(stack),
 			},
 			"rbac": map[string]map[string]interface{}{
 				"serviceAccount": {
@@ -66,18 +167,75 @@ func NewEksClusterAutoscaler(stack awscdk.Stack, cluster awseks.Cluster) {
 					"name":   caSa.ServiceAccountName(),
 				},
 			},
-			"extraArgs": map[string]interface{}{
-				"skip-nodes-with-system-pods":   jsii.Bool(false),
-				"skip-nodes-with-local-storage": jsii.Bool(false),
-				"balance-similar-node-groups":   jsii.Bool(true),
-				// How long a node should be unneeded before it is eligible for scale down
-				"scale-down-unneeded-time": jsii.String("300s"),
-				// How long after scale up that scale down evaluation resumes
-				"scale-down-delay-after-add": jsii.String("300s"),
-				// selects the node group that will have the least idle CPU (if tied, unused memory) after scale-up.
-				"expander": jsii.String("least-waste"),
-			},
+			"extraArgs": extraArgs,
 		},
 	})
 	caChart.Node().AddDependency(caSa)
+	if priorityConfigMap != nil {
+		caChart.Node().AddDependency(priorityConfigMap)
+	}
+}
+
+// Scale-down parameters of 'cdk.json/context/clusterAutoscaler', or defaults of the deployment stage.
+// DEV stage gives unneeded nodes back sooner, PROD stage keeps them longer to absorb bursts.
+func autoscalerScaleDownArgs(stack awscdk.Stack, clusterAutoscaler config.ClusterAutoscalerConfig) map[string]string {
+	args := map[string]string{
+		// How long a node should be unneeded before it is eligible for scale down
+		"scale-down-unneeded-time": "10m",
+		// How long after scale up that scale down evaluation resumes
+		"scale-down-delay-after-add":       "10m",
+		"scale-down-utilization-threshold": "0.5",
+	}
+	if config.DeploymentStage(stack) == config.DeploymentStage_DEV {
+		args["scale-down-unneeded-time"] = "5m"
+		args["scale-down-delay-after-add"] = "5m"
+	}
+
+	if len(clusterAutoscaler.ScaleDownUnneededTime) > 0 {
+		args["scale-down-unneeded-time"] = clusterAutoscaler.ScaleDownUnneededTime
+	}
+	if len(clusterAutoscaler.ScaleDownDelayAfterAdd) > 0 {
+		args["scale-down-delay-after-add"] = clusterAutoscaler.ScaleDownDelayAfterAdd
+	}
+	if len(clusterAutoscaler.ScaleDownUtilizationThreshold) > 0 {
+		args["scale-down-utilization-threshold"] = clusterAutoscaler.ScaleDownUtilizationThreshold
+	}
+
+	return args
+}
+
+// ConfigMap of the priority expander, higher values are preferred.
+// Auto Scaling groups of managed nodegroups are named eks-<nodegroup name>-<uuid>.
+func newAutoscalerPriorityConfigMap(cluster awseks.Cluster, nodegroups []AutoscaledNodegroup) awseks.KubernetesManifest {
+	groupsByPriority := map[int][]string{}
+	for _, nodegroup := range nodegroups {
+		priority := autoscalerPriorities[nodegroup.CapacityType]
+		groupsByPriority[priority] = append(groupsByPriority[priority], "^eks-"+nodegroup.Name+"-.*")
+	}
+
+	var priorityValues []int
+	for priority := range groupsByPriority {
+		priorityValues = append(priorityValues, priority)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorityValues)))
+
+	var priorities strings.Builder
+	for _, priority := range priorityValues {
+		fmt.Fprintf(&priorities, "%d:\n", priority)
+		for _, pattern := range groupsByPriority[priority] {
+			fmt.Fprintf(&priorities, "  - %s\n", pattern)
+		}
+	}
+
+	return cluster.AddManifest(jsii.String("ClusterAutoscalerPriorityExpander"), &map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "cluster-autoscaler-priority-expander",
+			"namespace": "kube-system",
+		},
+		"data": map[string]string{
+			"priorities": priorities.String(),
+		},
+	})
 }
@@ -1,17 +1,25 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
-	"github.com/aws/jsii-runtime-go"
 )
 
-// // Install CoreDNS add-on
-func NewEksCoreDns(stack awscdk.Stack, cluster awseks.Cluster) {
-	awseks.NewCfnAddon(stack, jsii.String("CoreDNSAddon"), &awseks.CfnAddonProps{
-		AddonName:        jsii.String("coredns"),
-		ResolveConflicts: jsii.String("OVERWRITE"),
-		ClusterName:      cluster.ClusterName(),
-		AddonVersion:     jsii.String("v1.8.4-eksbuild.1"),
+// Install CoreDNS add-on
+func NewEksCoreDns(stack awscdk.Stack, cluster awseks.Cluster) awseks.CfnAddon {
+	configurationValues := map[string]interface{}{
+		"replicaCount": 2,
+	}
+	// Remove the eks.amazonaws.com/compute-type: ec2 annotation that keeps CoreDNS off Fargate.
+	if config.KubeSystemOnFargate(stack) {
+		configurationValues["computeType"] = "Fargate"
+	}
+
+	return newManagedAddon(stack, cluster, &managedAddonProps{
+		Id:                  "CoreDNS",
+		AddonName:           "coredns",
+		ConfigurationValues: configurationValues,
 	})
 }
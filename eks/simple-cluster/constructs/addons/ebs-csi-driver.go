@@ -7,8 +7,12 @@ import (
 	"github.com/aws/jsii-runtime-go"
 )
 
-// Install EBS CSI driver
-func NewEksEbsCsiDriver(stack awscdk.Stack, cluster awseks.Cluster) {
+// Default StorageClass of PersistentVolumeClaims, provisioned by EBS CSI driver.
+const gp3StorageClass = "gp3"
+
+// Install EBS CSI driver, and make encrypted gp3 the default StorageClass instead of in-tree gp2.
+// It returns the gp3 StorageClass.
+func NewEksEbsCsiDriver(stack awscdk.Stack, cluster awseks.Cluster) awseks.KubernetesManifest {
 	// Create IAM Policy for EBS CSI driver
 	ebsCsiPolicy := awsiam.NewPolicyDocument(&awsiam.PolicyDocumentProps{
 		AssignSids: jsii.Bool(true),
@@ -192,66 +196,56 @@ func NewEksEbsCsiDriver(stack awscdk.Stack, cluster awseks.Cluster) {
 		},
 	})
 
-	// For arm64/aarch64 cluster
-	/*
-		ebsCsiSa := awseks.NewServiceAccount(stack, jsii.String("EBSCSIDriverSA"), &awseks.ServiceAccountProps{
-			Name:      jsii.String("ebs-csi-controller-sa"),
-			Cluster:   cluster,
-			Namespace: jsii.String("kube-system"),
-		})
+	ebsCsiDriver := newManagedAddon(stack, cluster, &managedAddonProps{
+		Id:                 "EBSCSIDriver",
+		AddonName:          "aws-ebs-csi-driver",
+		RoleName:           "AmazonEKSEBSCSIRole",
+		Namespace:          "kube-system",
+		ServiceAccountName: "ebs-csi-controller-sa",
+		PolicyName:         "AmazonEKS_EBS_CSI_Driver_Policy",
+		PolicyDocument:     ebsCsiPolicy,
+	})
 
-		awsiam.NewPolicy(stack, jsii.String("EBSCSIDriverPolicy"), &awsiam.PolicyProps{
-			Document:   ebsCsiPolicy,
-			PolicyName: jsii.String(*stack.StackName() + "-AmazonEKS_EBS_CSI_Driver_Policy"),
-			Roles: &[]awsiam.IRole{
-				ebsCsiSa.Role(),
+	storageClass := cluster.AddManifest(jsii.String("GP3StorageClass"), &map[string]interface{}{
+		"apiVersion": "storage.k8s.io/v1",
+		"kind":       "StorageClass",
+		"metadata": map[string]interface{}{
+			"name": gp3StorageClass,
+			"annotations": map[string]string{
+				"storageclass.kubernetes.io/is-default-class": "true",
 			},
-		})
+		},
+		"provisioner": "ebs.csi.aws.com",
+		"parameters": map[string]string{
+			"type":      "gp3",
+			"encrypted": "true",
+		},
+		"reclaimPolicy":        "Delete",
+		"volumeBindingMode":    "WaitForFirstConsumer",
+		"allowVolumeExpansion": true,
+	})
+	storageClass.Node().AddDependency(ebsCsiDriver)
 
-		// https://github.com/kubernetes-sigs/aws-ebs-csi-driver/tree/master/charts/aws-ebs-csi-driver
-		ebsCsiChart := awseks.NewHelmChart(stack, jsii.String("EBSCSIDriverChart"), &awseks.HelmChartProps{
-			Repository:      jsii.String("https://kubernetes-sigs.github.io/aws-ebs-csi-driver"),
-			Release:         jsii.String("aws-ebs-csi-driver"),
-			Cluster:         cluster,
-			Chart:           jsii.String("aws-ebs-csi-driver"),
-			Namespace:       jsii.String("kube-system"),
-			CreateNamespace: jsii.Bool(true),
-			Wait:            jsii.Bool(true),
-			Version:         jsii.String("2.6.4"),
-			Values: &map[string]interface{}{
-				"controller": map[string]map[string]interface{}{
-					"serviceAccount": {
-						"create": jsii.Bool(false),
-						"name":   ebsCsiSa.ServiceAccountName(),
-					},
-					"extraVolumeTags": {
-						"eks:cluster-name": cluster.ClusterName(),
-					},
+	// PersistentVolumeClaims without storageClassName fail if more than one StorageClass is default.
+	gp2Patch := awseks.NewKubernetesPatch(stack, jsii.String("GP2StorageClassPatch"), &awseks.KubernetesPatchProps{
+		Cluster:      cluster,
+		ResourceName: jsii.String("storageclass/gp2"),
+		ApplyPatch: &map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					"storageclass.kubernetes.io/is-default-class": "false",
 				},
 			},
-		})
-		ebsCsiChart.Node().AddDependency(ebsCsiSa)
-	*/
-
-	// For x86_64/amd64 cluster
-	ebsCsiRole := awsiam.NewRole(stack, jsii.String("EBSCSIDriverRole"), &awsiam.RoleProps{
-		RoleName: jsii.String(*stack.StackName() + "-" + *stack.Region() + "-AmazonEKSEBSCSIRole"),
-		AssumedBy: awsiam.NewWebIdentityPrincipal(cluster.OpenIdConnectProvider().OpenIdConnectProviderArn(), &map[string]interface{}{
-			"StringEquals": awscdk.NewCfnJson(stack, jsii.String("CfnJson-EBSCSIDriverRole"), &awscdk.CfnJsonProps{
-				Value: map[string]string{
-					*cluster.OpenIdConnectProvider().OpenIdConnectProviderIssuer() + ":sub": "system:serviceaccount:kube-system:ebs-csi-controller-sa",
+		},
+		RestorePatch: &map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					"storageclass.kubernetes.io/is-default-class": "true",
 				},
-			}),
-		}),
-		InlinePolicies: &map[string]awsiam.PolicyDocument{
-			"AmazonEKS_EBS_CSI_Driver_Policy": ebsCsiPolicy,
+			},
 		},
 	})
+	storageClass.Node().AddDependency(gp2Patch)
 
-	awseks.NewCfnAddon(stack, jsii.String("EBSCSIDriverAddon"), &awseks.CfnAddonProps{
-		AddonName:             jsii.String("aws-ebs-csi-driver"),
-		ClusterName:           cluster.ClusterName(),
-		ServiceAccountRoleArn: ebsCsiRole.RoleArn(),
-		AddonVersion:          jsii.String("v1.10.0-eksbuild.1"),
-	})
+	return storageClass
 }
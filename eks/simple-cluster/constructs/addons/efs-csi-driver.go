@@ -0,0 +1,95 @@
+package addons
+
+import (
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsefs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install EFS CSI driver with an EFS file system in the cluster VPC, nodes of nodeSG mount it by NFS.
+// StorageClass efs-sc dynamically provisions a ReadWriteMany volume by an access point per PersistentVolumeClaim.
+func NewEksEfsCsiDriver(stack awscdk.Stack, cluster awseks.Cluster, nodeSG awsec2.ISecurityGroup) {
+	efsSG := awsec2.NewSecurityGroup(stack, jsii.String("EfsSG"), &awsec2.SecurityGroupProps{
+		Vpc:              cluster.Vpc(),
+		AllowAllOutbound: jsii.Bool(false),
+		Description:      jsii.String("EFS mount targets of EKS cluster " + config.ClusterName(stack)),
+	})
+	efsSG.AddIngressRule(nodeSG, awsec2.Port_Tcp(jsii.Number(2049)),
+		jsii.String("Allow nodes to mount EFS by NFS."), jsii.Bool(false))
+
+	// Mount targets are created in private subnets, or public subnets if the VPC has no private subnets (DEV stage).
+	subnetGroupName := config.PrivateSubnetGroupName
+	if config.DeploymentStage(stack) != config.DeploymentStage_PROD {
+		subnetGroupName = config.PublicSubnetGroupName
+	}
+	// Keep the data of PROD when the stack is deleted.
+	removalPolicy := awscdk.RemovalPolicy_DESTROY
+	if config.DeploymentStage(stack) == config.DeploymentStage_PROD {
+		removalPolicy = awscdk.RemovalPolicy_RETAIN
+	}
+	fileSystem := awsefs.NewFileSystem(stack, jsii.String("EfsFileSystem"), &awsefs.FileSystemProps{
+		Vpc: cluster.Vpc(),
+		VpcSubnets: &awsec2.SubnetSelection{
+			SubnetGroupName: jsii.String(subnetGroupName),
+		},
+		SecurityGroup:   efsSG,
+		FileSystemName:  jsii.String(*stack.StackName() + "-EfsFileSystem"),
+		Encrypted:       jsii.Bool(true),
+		ThroughputMode:  awsefs.ThroughputMode_ELASTIC,
+		PerformanceMode: awsefs.PerformanceMode_GENERAL_PURPOSE,
+		LifecyclePolicy: awsefs.LifecyclePolicy_AFTER_30_DAYS,
+		RemovalPolicy:   removalPolicy,
+	})
+
+	efsCsiSa := newServiceAccount(stack, cluster, "EFSCSIDriverSA", "kube-system", "efs-csi-controller-sa")
+	efsCsiSa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("service-role/AmazonEFSCSIDriverPolicy")))
+
+	// https://github.com/kubernetes-sigs/aws-efs-csi-driver/tree/master/charts/aws-efs-csi-driver
+	efsCsiChart := awseks.NewHelmChart(stack, jsii.String("EFSCSIDriverChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://kubernetes-sigs.github.io/aws-efs-csi-driver"),
+		Release:    jsii.String("aws-efs-csi-driver"),
+		Cluster:    cluster,
+		Chart:      jsii.String("aws-efs-csi-driver"),
+		Namespace:  jsii.String("kube-system"),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(config.ChartVersion(stack, "aws-efs-csi-driver")),
+		Values: &map[string]interface{}{
+			"controller": map[string]interface{}{
+				"serviceAccount": map[string]interface{}{
+					"create": jsii.Bool(false),
+					"name":   efsCsiSa.ServiceAccountName(),
+				},
+			},
+		},
+	})
+	efsCsiChart.Node().AddDependency(efsCsiSa)
+
+	storageClass := cluster.AddManifest(jsii.String("EFSStorageClass"), &map[string]interface{}{
+		"apiVersion": "storage.k8s.io/v1",
+		"kind":       "StorageClass",
+		"metadata": map[string]interface{}{
+			"name": "efs-sc",
+		},
+		"provisioner": "efs.csi.aws.com",
+		"parameters": map[string]string{
+			"provisioningMode": "efs-ap",
+			"fileSystemId":     *fileSystem.FileSystemId(),
+			"directoryPerms":   "700",
+			"basePath":         "/dynamic",
+		},
+		"reclaimPolicy":     "Delete",
+		"volumeBindingMode": "Immediate",
+	})
+	storageClass.Node().AddDependency(efsCsiChart)
+	// Volumes can only be mounted after the mount targets are available.
+	storageClass.Node().AddDependency(fileSystem.MountTargetsAvailable())
+
+	awscdk.NewCfnOutput(stack, jsii.String("efsFileSystemId"), &awscdk.CfnOutputProps{
+		Value: fileSystem.FileSystemId(),
+	})
+}
@@ -32,6 +32,7 @@ func NewEksExternalDNS(stack awscdk.Stack, cluster awseks.Cluster) {
 			},
 		})
 	} else { // Otherwise, we define a policy with the corresponding permissions.
+		hostedZoneArns := externalDnsHostedZoneArns(stack)
 		externalDnsPolicy = awsiam.NewPolicyDocument(&awsiam.PolicyDocumentProps{
 			AssignSids: jsii.Bool(true),
 			Statements: &[]awsiam.PolicyStatement{
@@ -39,16 +40,14 @@ func NewEksExternalDNS(stack awscdk.Stack, cluster awseks.Cluster) {
 					Effect: awsiam.Effect_ALLOW,
 					Actions: &[]*string{
 						jsii.String("route53:ChangeResourceRecordSets"),
+						jsii.String("route53:ListResourceRecordSets"),
 					},
-					Resources: &[]*string{
-						jsii.String("arn:aws:route53:::hostedzone/*"),
-					},
+					Resources: &hostedZoneArns,
 				}),
 				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
 					Effect: awsiam.Effect_ALLOW,
 					Actions: &[]*string{
 						jsii.String("route53:ListHostedZones"),
-						jsii.String("route53:ListResourceRecordSets"),
 					},
 					Resources: &[]*string{
 						jsii.String("*"),
@@ -59,11 +58,7 @@ func NewEksExternalDNS(stack awscdk.Stack, cluster awseks.Cluster) {
 	}
 
 	// Create K8s service account in default namespace.
-	externalDnsSa := awseks.NewServiceAccount(stack, jsii.String("ExternalDNSSA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("external-dns"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
+	externalDnsSa := newServiceAccount(stack, cluster, "ExternalDNSSA", "kube-system", "external-dns")
 
 	// Associate the policy with that service account.
 	awsiam.NewPolicy(stack, jsii.String("ExternalDNSPolicy"), &awsiam.PolicyProps{
@@ -88,10 +83,20 @@ func NewEksExternalDNS(stack awscdk.Stack, cluster awseks.Cluster) {
 		Namespace:       jsii.String("kube-system"),
 		CreateNamespace: jsii.Bool(true),
 		Wait:            jsii.Bool(true),
-		Version:         jsii.String("6.2.3"),
+		Version:         jsii.String(config.ChartVersion(stack, "external-dns")),
 		Values: &map[string]interface{}{
+			// Bitnami's image of old charts is x86 only, the upstream image is multi-arch.
+			"image": map[string]interface{}{
+				"registry":   jsii.String("registry.k8s.io"),
+				"repository": jsii.String("external-dns/external-dns"),
+				"tag":        jsii.String(config.ExternalDnsImageTag),
+			},
 			"provider": jsii.String("aws"),
 			"policy":   jsii.String("sync"),
+			// TXT records mark the records owned by this cluster, ExternalDNS of other owner IDs leaves them alone.
+			"txtOwnerId":    jsii.String(config.ExternalDnsTxtOwnerId(stack)),
+			"domainFilters": jsii.Strings(config.ExternalDnsDomainFilters(stack)...),
+			"zoneIdFilters": jsii.Strings(config.ExternalDnsHostedZoneIds(stack)...),
 			"serviceAccount": map[string]interface{}{
 				"create": jsii.Bool(false),
 				"name":   externalDnsSa.ServiceAccountName(),
@@ -103,3 +108,25 @@ func NewEksExternalDNS(stack awscdk.Stack, cluster awseks.Cluster) {
 	})
 	externalDnsChart.Node().AddDependency(externalDnsSa)
 }
+
+// ARNs of the hosted zones of 'cdk.json/context/externalDnsHostedZoneIds', or all hosted zones if it's empty.
+// Hosted zones are global resources.
+func externalDnsHostedZoneArns(stack awscdk.Stack) []*string {
+	hostedZoneIds := config.ExternalDnsHostedZoneIds(stack)
+	if len(hostedZoneIds) == 0 {
+		hostedZoneIds = []string{"*"}
+	}
+
+	var hostedZoneArns []*string
+	for _, hostedZoneId := range hostedZoneIds {
+		hostedZoneArns = append(hostedZoneArns, stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("route53"),
+			Region:       jsii.String(""),
+			Account:      jsii.String(""),
+			Resource:     jsii.String("hostedzone"),
+			ResourceName: jsii.String(hostedZoneId),
+		}))
+	}
+
+	return hostedZoneArns
+}
@@ -0,0 +1,40 @@
+package addons
+
+import (
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install External Secrets Operator, it syncs secrets of AWS Secrets Manager to K8s secrets.
+// The operator is shared by all addons that need it, grant it read access to each secret by its service account's role.
+func newEksExternalSecrets(stack awscdk.Stack, cluster awseks.Cluster) (awseks.HelmChart, awseks.ServiceAccount) {
+	if chart, ok := stack.Node().TryFindChild(jsii.String("ExternalSecretsChart")).(awseks.HelmChart); ok {
+		return chart, stack.Node().FindChild(jsii.String("ExternalSecretsSA")).(awseks.ServiceAccount)
+	}
+
+	esoSa := newServiceAccount(stack, cluster, "ExternalSecretsSA", "kube-system", "external-secrets")
+
+	// https://github.com/external-secrets/external-secrets/tree/main/deploy/charts/external-secrets
+	esoChart := awseks.NewHelmChart(stack, jsii.String("ExternalSecretsChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://charts.external-secrets.io"),
+		Release:    jsii.String("external-secrets"),
+		Cluster:    cluster,
+		Chart:      jsii.String("external-secrets"),
+		Namespace:  jsii.String("kube-system"),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(config.ChartVersion(stack, "external-secrets")),
+		Values: &map[string]interface{}{
+			"installCRDs": jsii.Bool(true),
+			"serviceAccount": map[string]interface{}{
+				"create": jsii.Bool(false),
+				"name":   esoSa.ServiceAccountName(),
+			},
+		},
+	})
+	esoChart.Node().AddDependency(esoSa)
+
+	return esoChart, esoSa
+}
@@ -1,40 +1,150 @@
 package addons
 
 import (
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskinesisfirehose"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
 	"github.com/aws/jsii-runtime-go"
 )
 
+// Retention days accepted by CloudWatch Logs.
+var logRetentionDays = []int{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
 // Install AWS for fluent bit.
+// Containers' logs are routed to the destinations of 'cdk.json/context/logRouting',
+// the service account's policy only allows writing to the enabled destinations.
 func NewEksFluentBit(stack awscdk.Stack, cluster awseks.Cluster) {
-	fbSa := awseks.NewServiceAccount(stack, jsii.String("FluentBitSA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("fluent-bit"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
-	fbSa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("CloudWatchAgentServerPolicy")))
+	logRouting := config.LogRouting(stack)
+	if !logRouting.CloudWatch.Enabled && !logRouting.Firehose.Enabled && !logRouting.OpenSearch.Enabled {
+		awscdk.Annotations_Of(stack).AddWarning(jsii.String("No log destination is enabled in logRouting, Fluent Bit is not installed."))
+		return
+	}
+
+	var outputs []string
+	var statements []awsiam.PolicyStatement
+
+	if logRouting.CloudWatch.Enabled {
+		validRetention := false
+		for _, days := range logRetentionDays {
+			validRetention = validRetention || days == logRouting.CloudWatch.RetentionDays
+		}
+		if !validRetention {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Log retention days %d is not valid, valid values are: %v.", logRouting.CloudWatch.RetentionDays, logRetentionDays)))
+		}
+
+		logGroupName := "/aws/containerinsights/" + *cluster.ClusterName() + "/application"
+		logGroupArn := stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("logs"),
+			Resource:     jsii.String("log-group"),
+			ResourceName: jsii.String(logGroupName),
+			ArnFormat:    awscdk.ArnFormat_COLON_RESOURCE_NAME,
+		})
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("logs:CreateLogGroup"),
+				jsii.String("logs:PutRetentionPolicy"),
+				jsii.String("logs:CreateLogStream"),
+				jsii.String("logs:DescribeLogStreams"),
+				jsii.String("logs:PutLogEvents"),
+			},
+			Resources: &[]*string{
+				logGroupArn,
+				jsii.String(*logGroupArn + ":*"),
+			},
+		}))
+
+		outputs = append(outputs, fluentBitOutput("cloudwatch_logs", logRouting.CloudWatch.Namespaces, [][2]string{
+			{"region", *stack.Region()},
+			{"log_group_name", logGroupName},
+			{"log_stream_prefix", "fluentbit-"},
+			{"auto_create_group", "true"},
+			{"log_retention_days", fmt.Sprint(logRouting.CloudWatch.RetentionDays)},
+		}))
+	}
+
+	if logRouting.Firehose.Enabled {
+		deliveryStream := newLogDeliveryStream(stack, logRouting.Firehose)
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("firehose:PutRecordBatch"),
+			},
+			Resources: &[]*string{
+				deliveryStream.AttrArn(),
+			},
+		}))
+
+		outputs = append(outputs, fluentBitOutput("kinesis_firehose", logRouting.Firehose.Namespaces, [][2]string{
+			{"region", *stack.Region()},
+			{"delivery_stream", *deliveryStream.Ref()},
+			{"time_key", "time"},
+		}))
+	}
+
+	if logRouting.OpenSearch.Enabled {
+		endpoint := strings.TrimSuffix(strings.TrimPrefix(logRouting.OpenSearch.Endpoint, "https://"), "/")
+		if len(endpoint) == 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String("OpenSearch log destination requires a domain endpoint, set logRouting/openSearch/endpoint."))
+			return
+		}
+
+		// Endpoint is search-<domain>-<id>.<region>.es.amazonaws.com, or vpc-<domain>-<id>... in VPC.
+		domainName := strings.SplitN(endpoint, ".", 2)[0]
+		domainName = strings.TrimPrefix(strings.TrimPrefix(domainName, "search-"), "vpc-")
+		if i := strings.LastIndex(domainName, "-"); i > 0 {
+			domainName = domainName[:i]
+		}
+		domainArn := stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("es"),
+			Resource:     jsii.String("domain"),
+			ResourceName: jsii.String(domainName),
+		})
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("es:ESHttpPost"),
+				jsii.String("es:ESHttpPut"),
+			},
+			Resources: &[]*string{
+				domainArn,
+				jsii.String(*domainArn + "/*"),
+			},
+		}))
+
+		outputs = append(outputs, fluentBitOutput("es", logRouting.OpenSearch.Namespaces, [][2]string{
+			{"Host", endpoint},
+			{"Port", "443"},
+			{"tls", "On"},
+			{"AWS_Auth", "On"},
+			{"AWS_Region", *stack.Region()},
+			{"Index", logRouting.OpenSearch.Index},
+			{"Replace_Dots", "On"},
+			{"Suppress_Type_Name", "On"},
+			{"Trace_Error", "On"},
+		}))
+	}
+
+	fbSa := newServiceAccount(stack, cluster, "FluentBitSA", "kube-system", "fluent-bit")
 
 	awsiam.NewPolicy(stack, jsii.String("FluentBitPolicy"), &awsiam.PolicyProps{
 		PolicyName: jsii.String(*stack.StackName() + "-FluentBitPolicy"),
 		Roles: &[]awsiam.IRole{
 			fbSa.Role(),
 		},
-		Statements: &[]awsiam.PolicyStatement{
-			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-				Effect: awsiam.Effect_ALLOW,
-				Actions: &[]*string{
-					jsii.String("logs:PutRetentionPolicy"),
-				},
-				Resources: &[]*string{
-					jsii.String("*"),
-				},
-			}),
-		},
+		Statements: &statements,
 	})
 
 	// https://github.com/aws/eks-charts/tree/master/stable/aws-for-fluent-bit
+	// Outputs of the chart only match one tag each, the routes are rendered as additional outputs instead.
 	awseks.NewHelmChart(stack, jsii.String("FluentBitChart"), &awseks.HelmChartProps{
 		Repository:      jsii.String("https://aws.github.io/eks-charts"),
 		Release:         jsii.String("aws-for-fluent-bit"),
@@ -43,19 +153,17 @@ func NewEksFluentBit(stack awscdk.Stack, cluster awseks.Cluster) {
 		Namespace:       jsii.String("kube-system"),
 		CreateNamespace: jsii.Bool(true),
 		Wait:            jsii.Bool(true),
-		Version:         jsii.String("0.1.15"),
+		Version:         jsii.String(config.ChartVersion(stack, "aws-for-fluent-bit")),
 		Values: &map[string]interface{}{
 			"serviceAccount": map[string]interface{}{
 				"create": jsii.Bool(false),
 				"name":   fbSa.ServiceAccountName(),
 			},
 			"cloudWatch": map[string]interface{}{
-				"enabled":          jsii.Bool(true),
-				"match":            jsii.String("*"),
-				"region":           stack.Region(),
-				"logGroupName":     jsii.String("/aws/containerinsights/" + *cluster.ClusterName() + "/application"),
-				"autoCreateGroup":  jsii.Bool(true),
-				"logRetentionDays": jsii.Number(1),
+				"enabled": jsii.Bool(false),
+			},
+			"cloudWatchLogs": map[string]interface{}{
+				"enabled": jsii.Bool(false),
 			},
 			"kinesis": map[string]interface{}{
 				"enabled": jsii.Bool(false),
@@ -66,6 +174,77 @@ func NewEksFluentBit(stack awscdk.Stack, cluster awseks.Cluster) {
 			"elasticsearch": map[string]interface{}{
 				"enabled": jsii.Bool(false),
 			},
+			"additionalOutputs": jsii.String(strings.Join(outputs, "\n")),
+			"tolerations":       spotTolerations,
+		},
+	})
+}
+
+// Firehose delivery stream that writes gzipped logs to a new S3 bucket.
+func newLogDeliveryStream(stack awscdk.Stack, firehose config.FirehoseLogDestination) awskinesisfirehose.CfnDeliveryStream {
+	var lifecycleRules *[]*awss3.LifecycleRule = nil
+	if firehose.ExpirationDays > 0 {
+		lifecycleRules = &[]*awss3.LifecycleRule{
+			{
+				Expiration: awscdk.Duration_Days(jsii.Number(float64(firehose.ExpirationDays))),
+			},
+		}
+	}
+	bucket := awss3.NewBucket(stack, jsii.String("LogsBucket"), &awss3.BucketProps{
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        jsii.Bool(true),
+		LifecycleRules:    lifecycleRules,
+	})
+
+	firehoseRole := awsiam.NewRole(stack, jsii.String("LogsDeliveryStreamRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("firehose.amazonaws.com"), nil),
+	})
+	bucket.GrantReadWrite(firehoseRole, nil)
+
+	deliveryStream := awskinesisfirehose.NewCfnDeliveryStream(stack, jsii.String("LogsDeliveryStream"), &awskinesisfirehose.CfnDeliveryStreamProps{
+		DeliveryStreamType: jsii.String("DirectPut"),
+		DeliveryStreamEncryptionConfigurationInput: &awskinesisfirehose.CfnDeliveryStream_DeliveryStreamEncryptionConfigurationInputProperty{
+			KeyType: jsii.String("AWS_OWNED_CMK"),
+		},
+		ExtendedS3DestinationConfiguration: &awskinesisfirehose.CfnDeliveryStream_ExtendedS3DestinationConfigurationProperty{
+			BucketArn:         bucket.BucketArn(),
+			RoleArn:           firehoseRole.RoleArn(),
+			Prefix:            jsii.String("logs/!{timestamp:yyyy/MM/dd}/"),
+			ErrorOutputPrefix: jsii.String("errors/!{firehose:error-output-type}/!{timestamp:yyyy/MM/dd}/"),
+			CompressionFormat: jsii.String("GZIP"),
+			BufferingHints: &awskinesisfirehose.CfnDeliveryStream_BufferingHintsProperty{
+				IntervalInSeconds: jsii.Number(300),
+				SizeInMBs:         jsii.Number(5),
+			},
 		},
 	})
+	// Firehose checks the bucket permissions when the delivery stream is created.
+	deliveryStream.Node().AddDependency(firehoseRole)
+
+	awscdk.NewCfnOutput(stack, jsii.String("logsBucketName"), &awscdk.CfnOutputProps{
+		Value: bucket.BucketName(),
+	})
+
+	return deliveryStream
+}
+
+// Fluent Bit [OUTPUT] section, it matches containers' logs of the namespaces, or all logs if namespaces is empty.
+// Tags of containers' logs are kube.var.log.containers.<pod>_<namespace>_<container>-<id>.log.
+func fluentBitOutput(name string, namespaces []string, options [][2]string) string {
+	lines := []string{
+		"[OUTPUT]",
+		fmt.Sprintf("    %-20s %s", "Name", name),
+	}
+	if len(namespaces) == 0 {
+		lines = append(lines, fmt.Sprintf("    %-20s %s", "Match", "kube.*"))
+	} else {
+		lines = append(lines, fmt.Sprintf("    %-20s %s", "Match_Regex",
+			`^kube\.var\.log\.containers\.[^_]+_(`+strings.Join(namespaces, "|")+`)_.+$`))
+	}
+	for _, option := range options {
+		lines = append(lines, fmt.Sprintf("    %-20s %s", option[0], option[1]))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
 }
@@ -0,0 +1,226 @@
+package addons
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// K8s secret of Git repository credentials, synced from Secrets Manager by External Secrets Operator.
+// Credentials are never passed through CloudFormation, because CDK's kubectl handler logs the manifests.
+const gitOpsCredentialsName = "gitops-repo-credentials"
+
+// Install GitOps engine, Argo CD or Flux, and sync the cluster from the Git repository of 'cdk.json/context/gitOps'.
+func NewEksGitOps(stack awscdk.Stack, cluster awseks.Cluster) {
+	gitOps := config.GitOps(stack)
+	if len(gitOps.RepoUrl) == 0 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String("GitOps requires a Git repository, set gitOps/repoUrl."))
+		return
+	}
+
+	var namespace string
+	var engineChart awseks.HelmChart
+	switch gitOps.Engine {
+	case config.GitOpsEngine_ARGOCD:
+		namespace = "argocd"
+		// https://github.com/argoproj/argo-helm/tree/main/charts/argo-cd
+		engineChart = awseks.NewHelmChart(stack, jsii.String("ArgoCDChart"), &awseks.HelmChartProps{
+			Repository:      jsii.String("https://argoproj.github.io/argo-helm"),
+			Release:         jsii.String("argocd"),
+			Cluster:         cluster,
+			Chart:           jsii.String("argo-cd"),
+			Namespace:       jsii.String(namespace),
+			CreateNamespace: jsii.Bool(true),
+			Wait:            jsii.Bool(true),
+			Version:         jsii.String(config.ChartVersion(stack, "argo-cd")),
+		})
+	case config.GitOpsEngine_FLUX:
+		namespace = "flux-system"
+		// https://github.com/fluxcd-community/helm-charts/tree/main/charts/flux2
+		engineChart = awseks.NewHelmChart(stack, jsii.String("FluxChart"), &awseks.HelmChartProps{
+			Repository:      jsii.String("https://fluxcd-community.github.io/helm-charts"),
+			Release:         jsii.String("flux"),
+			Cluster:         cluster,
+			Chart:           jsii.String("flux2"),
+			Namespace:       jsii.String(namespace),
+			CreateNamespace: jsii.Bool(true),
+			Wait:            jsii.Bool(true),
+			Version:         jsii.String(config.ChartVersion(stack, "flux2")),
+		})
+	default:
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"GitOps engine %s is not valid, valid values are: argocd, flux.", gitOps.Engine)))
+		return
+	}
+
+	// Sync repository credentials to the engine's namespace.
+	var credentials awseks.KubernetesManifest = nil
+	if len(gitOps.CredentialsSecret) > 0 {
+		var secret awssecretsmanager.ISecret
+		if strings.HasPrefix(gitOps.CredentialsSecret, "arn:") {
+			secret = awssecretsmanager.Secret_FromSecretCompleteArn(stack, jsii.String("GitOpsRepoSecret"), jsii.String(gitOps.CredentialsSecret))
+		} else {
+			secret = awssecretsmanager.Secret_FromSecretNameV2(stack, jsii.String("GitOpsRepoSecret"), jsii.String(gitOps.CredentialsSecret))
+		}
+
+		esoChart, esoSa := newEksExternalSecrets(stack, cluster)
+		secret.GrantRead(esoSa.Role(), nil)
+
+		credentials = cluster.AddManifest(jsii.String("GitOpsRepoCredentials"),
+			secretStore(stack, namespace),
+			gitOpsCredentials(gitOps, namespace))
+		credentials.Node().AddDependency(esoChart)
+		credentials.Node().AddDependency(engineChart)
+	}
+
+	// Root Application or Kustomization, it syncs everything else from the repository.
+	var rootManifests []*map[string]interface{}
+	if gitOps.Engine == config.GitOpsEngine_ARGOCD {
+		rootManifests = append(rootManifests, &map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Application",
+			"metadata": map[string]interface{}{
+				"name":      "root",
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"project": "default",
+				"source": map[string]interface{}{
+					"repoURL":        gitOps.RepoUrl,
+					"path":           gitOps.Path,
+					"targetRevision": gitOps.Revision,
+				},
+				"destination": map[string]interface{}{
+					"server":    "https://kubernetes.default.svc",
+					"namespace": namespace,
+				},
+				"syncPolicy": map[string]interface{}{
+					"automated": map[string]interface{}{
+						"prune":    true,
+						"selfHeal": true,
+					},
+				},
+			},
+		})
+	} else {
+		gitRepositorySpec := map[string]interface{}{
+			"interval": "1m",
+			"url":      gitOps.RepoUrl,
+			"ref": map[string]interface{}{
+				"branch": gitOps.Revision,
+			},
+		}
+		if credentials != nil {
+			gitRepositorySpec["secretRef"] = map[string]interface{}{
+				"name": gitOpsCredentialsName,
+			}
+		}
+		rootManifests = append(rootManifests, &map[string]interface{}{
+			"apiVersion": "source.toolkit.fluxcd.io/v1",
+			"kind":       "GitRepository",
+			"metadata": map[string]interface{}{
+				"name":      "root",
+				"namespace": namespace,
+			},
+			"spec": gitRepositorySpec,
+		}, &map[string]interface{}{
+			"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+			"kind":       "Kustomization",
+			"metadata": map[string]interface{}{
+				"name":      "root",
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"interval": "10m",
+				"path":     gitOps.Path,
+				"prune":    true,
+				"sourceRef": map[string]interface{}{
+					"kind": "GitRepository",
+					"name": "root",
+				},
+			},
+		})
+	}
+
+	root := cluster.AddManifest(jsii.String("GitOpsRoot"), rootManifests...)
+	root.Node().AddDependency(engineChart)
+	if credentials != nil {
+		root.Node().AddDependency(credentials)
+	}
+}
+
+// SecretStore of Secrets Manager in the stack's region, authenticated by External Secrets Operator's service account role.
+func secretStore(stack awscdk.Stack, namespace string) *map[string]interface{} {
+	return &map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "SecretStore",
+		"metadata": map[string]interface{}{
+			"name":      "aws-secrets-manager",
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"provider": map[string]interface{}{
+				"aws": map[string]interface{}{
+					"service": "SecretsManager",
+					"region":  *stack.Region(),
+				},
+			},
+		},
+	}
+}
+
+// Argo CD finds repository credentials by label, Flux by GitRepository's secretRef.
+func gitOpsCredentials(gitOps config.GitOpsConfig, namespace string) *map[string]interface{} {
+	template := map[string]interface{}{
+		"data": map[string]string{
+			"username": "{{ .username }}",
+			"password": "{{ .password }}",
+		},
+	}
+	if gitOps.Engine == config.GitOpsEngine_ARGOCD {
+		template["metadata"] = map[string]interface{}{
+			"labels": map[string]string{
+				"argocd.argoproj.io/secret-type": "repository",
+			},
+		}
+		template["data"] = map[string]string{
+			"type":     "git",
+			"url":      gitOps.RepoUrl,
+			"username": "{{ .username }}",
+			"password": "{{ .password }}",
+		}
+	}
+
+	return &map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata": map[string]interface{}{
+			"name":      gitOpsCredentialsName,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"refreshInterval": "1h",
+			"secretStoreRef": map[string]interface{}{
+				"name": "aws-secrets-manager",
+				"kind": "SecretStore",
+			},
+			"target": map[string]interface{}{
+				"name":     gitOpsCredentialsName,
+				"template": template,
+			},
+			"dataFrom": []interface{}{
+				map[string]interface{}{
+					"extract": map[string]interface{}{
+						"key": gitOps.CredentialsSecret,
+					},
+				},
+			},
+		},
+	}
+}
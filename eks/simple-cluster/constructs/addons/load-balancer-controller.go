@@ -1,7 +1,10 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/jsii-runtime-go"
@@ -9,7 +12,7 @@ import (
 
 // Install AWS Load Balancer Controller
 // https://docs.aws.amazon.com/eks/latest/userguide/aws-load-balancer-controller.html
-func NewEksLoadBalancerController(stack awscdk.Stack, cluster awseks.Cluster) {
+func NewEksLoadBalancerController(stack awscdk.Stack, cluster awseks.Cluster) awseks.HelmChart {
 	// Create IAM Policy for AWS Load Balancer Controller
 	lbcPolicy := awsiam.NewPolicyDocument(&awsiam.PolicyDocumentProps{
 		AssignSids: jsii.Bool(true),
@@ -273,11 +276,7 @@ func NewEksLoadBalancerController(stack awscdk.Stack, cluster awseks.Cluster) {
 		},
 	})
 
-	lbcSa := awseks.NewServiceAccount(stack, jsii.String("AWSLoadBalancerControllerSA"), &awseks.ServiceAccountProps{
-		Name:      jsii.String("aws-load-balancer-controller"),
-		Cluster:   cluster,
-		Namespace: jsii.String("kube-system"),
-	})
+	lbcSa := newServiceAccount(stack, cluster, "AWSLoadBalancerControllerSA", "kube-system", "aws-load-balancer-controller")
 
 	awsiam.NewPolicy(stack, jsii.String("AWSLoadBalancerControllerPolicy"), &awsiam.PolicyProps{
 		Document:   lbcPolicy,
@@ -290,6 +289,52 @@ func NewEksLoadBalancerController(stack awscdk.Stack, cluster awseks.Cluster) {
 	// https://github.com/kubernetes-sigs/aws-load-balancer-controller/tree/main/helm/aws-load-balancer-controller
 	// TODO: --set image.repository=
 	// TODO: https://docs.aws.amazon.com/eks/latest/userguide/add-ons-images.html
+	lbcValues := map[string]interface{}{
+		"clusterName": *cluster.ClusterName(),
+		"defaultTags": map[string]string{
+			"eks:cluster-name": *cluster.ClusterName(),
+		},
+		// Controller on Fargate can't get region and VPC from IMDS.
+		"region": jsii.String(*stack.Region()),
+		"vpcId":  cluster.Vpc().VpcId(),
+		"serviceAccount": map[string]interface{}{
+			"create": jsii.Bool(false),
+			"name":   lbcSa.ServiceAccountName(),
+			/*
+				"annotations": map[string]interface{}{
+					"eks.amazonaws.com/sts-regional-endpoints": jsii.Bool(true),
+				},
+			*/
+		},
+	}
+	// Nodes accept requests from the shared backend security group, see 'cdk.json/context/nodeIngress'.
+	if loadBalancerSg, ok := stack.Node().TryFindChild(jsii.String("LoadBalancerSG")).(awsec2.ISecurityGroup); ok {
+		lbcValues["backendSecurityGroup"] = loadBalancerSg.SecurityGroupId()
+	}
+	// Pods on Fargate have no node to be instance targets, load balancers must target pods' IPs.
+	// IPv6 pods are only reachable by their IPs, from dual-stack load balancers.
+	// defaultTargetType is the default of NLB Services, IngressClassParams' targetType is the default of ALB Ingresses
+	// of IngressClass alb. Chart 1.4.1 of Kubernetes before 1.25 supports neither, they must be annotated.
+	ingressClassParamsSpec := map[string]interface{}{}
+	if len(config.FargateProfiles(stack)) > 0 || config.Ipv6(stack) {
+		if config.KubernetesMinorVersion(stack) >= 25 {
+			lbcValues["defaultTargetType"] = "ip"
+			ingressClassParamsSpec["targetType"] = "ip"
+		} else {
+			awscdk.Annotations_Of(stack).AddWarning(jsii.String(
+				"AWS Load Balancer Controller of Kubernetes before 1.25 can't default to IP targets, annotate Ingresses with " +
+					"alb.ingress.kubernetes.io/target-type: ip and Services with service.beta.kubernetes.io/aws-load-balancer-nlb-target-type: ip."))
+		}
+	}
+	if config.Ipv6(stack) {
+		ingressClassParamsSpec["ipAddressType"] = "dualstack"
+	}
+	if len(ingressClassParamsSpec) > 0 {
+		lbcValues["ingressClassParams"] = map[string]interface{}{
+			"spec": ingressClassParamsSpec,
+		}
+	}
+
 	lbcChart := awseks.NewHelmChart(stack, jsii.String("AWSLoadBalancerControllerChart"), &awseks.HelmChartProps{
 		Repository:      jsii.String("https://aws.github.io/eks-charts"),
 		Release:         jsii.String("aws-load-balancer-controller"),
@@ -298,24 +343,10 @@ func NewEksLoadBalancerController(stack awscdk.Stack, cluster awseks.Cluster) {
 		Namespace:       jsii.String("kube-system"),
 		CreateNamespace: jsii.Bool(true),
 		Wait:            jsii.Bool(true),
-		Version:         jsii.String("1.4.1"),
-		Values: &map[string]interface{}{
-			"clusterName": *cluster.ClusterName(),
-			"defaultTags": map[string]string{
-				"eks:cluster-name": *cluster.ClusterName(),
-			},
-			"region": jsii.String(*stack.Region()),
-			"vpcId":  cluster.Vpc().VpcId(),
-			"serviceAccount": map[string]interface{}{
-				"create": jsii.Bool(false),
-				"name":   lbcSa.ServiceAccountName(),
-				/*
-					"annotations": map[string]interface{}{
-						"eks.amazonaws.com/sts-regional-endpoints": jsii.Bool(true),
-					},
-				*/
-			},
-		},
+		Version:         jsii.String(config.ChartVersion(stack, "aws-load-balancer-controller")),
+		Values:          &lbcValues,
 	})
 	lbcChart.Node().AddDependency(lbcSa)
+
+	return lbcChart
 }
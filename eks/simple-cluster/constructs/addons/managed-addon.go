@@ -0,0 +1,145 @@
+package addons
+
+import (
+	"encoding/json"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/customresources"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// EKS managed add-on properties
+type managedAddonProps struct {
+	// Construct id prefix, e.g. VPCCNI creates VPCCNIAddon, VPCCNIRole and VPCCNIVersion.
+	Id string
+	// EKS add-on name, e.g. vpc-cni.
+	AddonName string
+	// IAM role of the add-on's service account, bound by IRSA or EKS Pod Identity.
+	// Leave RoleName empty if the add-on doesn't call AWS APIs.
+	RoleName           string
+	Namespace          string
+	ServiceAccountName string
+	ManagedPolicies    []awsiam.IManagedPolicy
+	PolicyName         string
+	PolicyDocument     awsiam.PolicyDocument
+	// Add-on configuration values, see 'aws eks describe-addon-configuration' for the schema.
+	ConfigurationValues map[string]interface{}
+}
+
+// Install an EKS managed add-on with its service account role and configuration values.
+func newManagedAddon(stack awscdk.Stack, cluster awseks.Cluster, props *managedAddonProps) awseks.CfnAddon {
+	addonProps := &awseks.CfnAddonProps{
+		AddonName:        jsii.String(props.AddonName),
+		ResolveConflicts: jsii.String(config.AddonResolveConflicts(stack)),
+		ClusterName:      cluster.ClusterName(),
+		AddonVersion:     addonVersion(stack, props.Id, props.AddonName),
+	}
+
+	// Pods of the add-on call AWS APIs as soon as it's created, the policy must be attached before.
+	var policy awsiam.Policy = nil
+	if len(props.RoleName) > 0 {
+		role := newServiceAccountRole(stack, cluster, props.Id+"Role", props.RoleName, props.Namespace, props.ServiceAccountName)
+		for _, managedPolicy := range props.ManagedPolicies {
+			role.AddManagedPolicy(managedPolicy)
+		}
+		if props.PolicyDocument != nil {
+			policy = awsiam.NewPolicy(stack, jsii.String(props.Id+"Policy"), &awsiam.PolicyProps{
+				Document:   props.PolicyDocument,
+				PolicyName: jsii.String(*stack.StackName() + "-" + props.PolicyName),
+				Roles: &[]awsiam.IRole{
+					role,
+				},
+			})
+		}
+
+		if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+			addonProps.PodIdentityAssociations = &[]*awseks.CfnAddon_PodIdentityAssociationProperty{
+				{
+					ServiceAccount: jsii.String(props.ServiceAccountName),
+					RoleArn:        role.RoleArn(),
+				},
+			}
+		} else {
+			addonProps.ServiceAccountRoleArn = role.RoleArn()
+		}
+	}
+
+	if props.ConfigurationValues != nil {
+		configurationValues, err := json.Marshal(props.ConfigurationValues)
+		if err != nil {
+			panic(err)
+		}
+		addonProps.ConfigurationValues = jsii.String(string(configurationValues))
+	}
+
+	addon := awseks.NewCfnAddon(stack, jsii.String(props.Id+"Addon"), addonProps)
+	if policy != nil {
+		addon.Node().AddDependency(policy)
+	}
+	if addonProps.PodIdentityAssociations != nil {
+		addon.Node().AddDependency(cluster.EksPodIdentityAgent())
+	}
+
+	return addon
+}
+
+// Get the add-on version pinned in 'cdk.json/context/addonVersions'.
+// If there is no pinned version, a custom resource resolves the latest version
+// compatible with the cluster's Kubernetes version at deploy time.
+func addonVersion(stack awscdk.Stack, id string, addonName string) *string {
+	if version := config.AddonVersion(stack, addonName); len(version) > 0 {
+		return jsii.String(version)
+	}
+
+	addonVersionRes := awscdk.NewCustomResource(stack, jsii.String(id+"Version"), &awscdk.CustomResourceProps{
+		ServiceToken: addonVersionProvider(stack).ServiceToken(),
+		ResourceType: jsii.String(config.AddonVersionResourceType),
+		Properties: &map[string]interface{}{
+			"AddonName":         addonName,
+			"KubernetesVersion": config.KubernetesVersion(stack),
+		},
+	})
+
+	return addonVersionRes.GetAttString(jsii.String("AddonVersion"))
+}
+
+// All add-ons share one custom resource provider per stack.
+func addonVersionProvider(stack awscdk.Stack) customresources.Provider {
+	if provider, ok := stack.Node().TryFindChild(jsii.String(config.AddonVersionProviderName)).(customresources.Provider); ok {
+		return provider
+	}
+
+	// Create add-on version resolver lambda function.
+	// Compile it by 'functions/Makefile' before synth, cdk-cli-wrapper-dev.sh does this for you.
+	resolverFunc := awslambda.NewFunction(stack, jsii.String(config.AddonVersionFuncName), &awslambda.FunctionProps{
+		FunctionName: jsii.String(*stack.StackName() + "-" + config.AddonVersionFuncName),
+		Runtime:      awslambda.Runtime_PROVIDED_AL2023(),
+		Architecture: awslambda.Architecture_ARM_64(),
+		MemorySize:   jsii.Number(config.AddonVersionFuncMemorySize),
+		Timeout:      awscdk.Duration_Seconds(jsii.Number(config.AddonVersionFuncTimeout)),
+		Code:         awslambda.AssetCode_FromAsset(jsii.String(config.AddonVersionFuncCodePath), nil),
+		Handler:      jsii.String(config.AddonVersionFuncHandler),
+		LogRetention: awslogs.RetentionDays_ONE_WEEK,
+	})
+	resolverFunc.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("eks:DescribeAddonVersions"),
+		},
+		Resources: &[]*string{
+			jsii.String("*"),
+		},
+	}))
+
+	return customresources.NewProvider(stack, jsii.String(config.AddonVersionProviderName), &customresources.ProviderProps{
+		ProviderFunctionName: jsii.String(*stack.StackName() + "-" + config.AddonVersionProviderName),
+		OnEventHandler:       resolverFunc,
+		LogRetention:         awslogs.RetentionDays_ONE_WEEK,
+	})
+}
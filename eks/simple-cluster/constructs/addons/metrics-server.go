@@ -1,6 +1,8 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/jsii-runtime-go"
@@ -16,6 +18,6 @@ func NewEksMetricsServer(stack awscdk.Stack, cluster awseks.Cluster) {
 		Chart:      jsii.String("metrics-server"),
 		Namespace:  jsii.String("kube-system"),
 		Wait:       jsii.Bool(true),
-		Version:    jsii.String("3.8.2"),
+		Version:    jsii.String(config.ChartVersion(stack, "metrics-server")),
 	})
 }
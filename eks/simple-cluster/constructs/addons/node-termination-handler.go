@@ -1,26 +1,110 @@
 package addons
 
 import (
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
 	"github.com/aws/jsii-runtime-go"
 )
 
-// AWS Node Termination Handler
+// Tolerations of DaemonSets that must run on Spot nodes too, e.g. log and metric agents.
+var spotTolerations = []interface{}{
+	map[string]interface{}{
+		"key":      config.SpotTaintKey,
+		"operator": "Equal",
+		"value":    config.SpotTaintValue,
+		"effect":   "NoSchedule",
+	},
+}
+
+// AWS Node Termination Handler in queue processor mode.
+// EventBridge sends Spot interruptions, rebalance recommendations, instance state changes, scheduled events
+// and ASG lifecycle actions to an SQS queue, NTH cordons and drains the nodes of the events.
 func NewEksNodeTerminationHandler(stack awscdk.Stack, cluster awseks.Cluster) {
+	queue := awssqs.NewQueue(stack, jsii.String("NodeTerminationHandlerQueue"), &awssqs.QueueProps{
+		RetentionPeriod: awscdk.Duration_Minutes(jsii.Number(5)),
+		Encryption:      awssqs.QueueEncryption_SQS_MANAGED,
+		EnforceSSL:      jsii.Bool(true),
+	})
+
+	rules := []struct {
+		id      string
+		pattern *awsevents.EventPattern
+	}{
+		{"NTHSpotInterruptionRule", &awsevents.EventPattern{
+			Source:     jsii.Strings("aws.ec2"),
+			DetailType: jsii.Strings("EC2 Spot Instance Interruption Warning"),
+		}},
+		{"NTHRebalanceRule", &awsevents.EventPattern{
+			Source:     jsii.Strings("aws.ec2"),
+			DetailType: jsii.Strings("EC2 Instance Rebalance Recommendation"),
+		}},
+		{"NTHInstanceStateChangeRule", &awsevents.EventPattern{
+			Source:     jsii.Strings("aws.ec2"),
+			DetailType: jsii.Strings("EC2 Instance State-change Notification"),
+		}},
+		{"NTHScheduledChangeRule", &awsevents.EventPattern{
+			Source:     jsii.Strings("aws.health"),
+			DetailType: jsii.Strings("AWS Health Event"),
+			Detail: &map[string]interface{}{
+				"service":           []string{"EC2"},
+				"eventTypeCategory": []string{"scheduledChange"},
+			},
+		}},
+		{"NTHASGLifecycleRule", &awsevents.EventPattern{
+			Source:     jsii.Strings("aws.autoscaling"),
+			DetailType: jsii.Strings("EC2 Instance-terminate Lifecycle Action"),
+		}},
+	}
+	for _, rule := range rules {
+		awsevents.NewRule(stack, jsii.String(rule.id), &awsevents.RuleProps{
+			EventPattern: rule.pattern,
+			Targets: &[]awsevents.IRuleTarget{
+				awseventstargets.NewSqsQueue(queue, nil),
+			},
+		})
+	}
+
+	nthSa := newServiceAccount(stack, cluster, "NodeTerminationHandlerSA", "kube-system", "aws-node-termination-handler")
+	queue.GrantConsumeMessages(nthSa.Role())
+	nthSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("autoscaling:CompleteLifecycleAction"),
+			jsii.String("autoscaling:DescribeAutoScalingInstances"),
+			jsii.String("autoscaling:DescribeTags"),
+			jsii.String("ec2:DescribeInstances"),
+		},
+		Resources: &[]*string{
+			jsii.String("*"),
+		},
+	}))
+
 	// https://github.com/aws/aws-node-termination-handler/tree/main/config/helm/aws-node-termination-handler
-	awseks.NewHelmChart(stack, jsii.String("NodeTerminationHandlerChart"), &awseks.HelmChartProps{
+	nthChart := awseks.NewHelmChart(stack, jsii.String("NodeTerminationHandlerChart"), &awseks.HelmChartProps{
 		Repository: jsii.String("https://aws.github.io/eks-charts"),
 		Release:    jsii.String("aws-node-termination-handler"),
 		Cluster:    cluster,
 		Chart:      jsii.String("aws-node-termination-handler"),
 		Namespace:  jsii.String("kube-system"),
 		Wait:       jsii.Bool(true),
-		Version:    jsii.String("0.18.0"),
+		Version:    jsii.String(config.ChartVersion(stack, "aws-node-termination-handler")),
 		Values: &map[string]interface{}{
-			"enableSpotInterruptionDraining": jsii.Bool(true),
-			"enableRebalanceMonitoring":      jsii.Bool(true),
-			"enableScheduledEventDraining":   jsii.Bool(false),
+			"enableSqsTerminationDraining": jsii.Bool(true),
+			"queueURL":                     queue.QueueUrl(),
+			"awsRegion":                    stack.Region(),
+			// ASGs of managed nodegroups can't be tagged by the stack, events of instances that aren't nodes are ignored.
+			"checkASGTagBeforeDraining": jsii.Bool(false),
+			"serviceAccount": map[string]interface{}{
+				"create": jsii.Bool(false),
+				"name":   nthSa.ServiceAccountName(),
+			},
 		},
 	})
+	nthChart.Node().AddDependency(nthSa)
 }
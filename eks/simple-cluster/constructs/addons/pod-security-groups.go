@@ -0,0 +1,90 @@
+package addons
+
+import (
+	"fmt"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// SecurityGroupPolicy properties
+type SecurityGroupPolicyProps struct {
+	Name string
+	// Namespace must exist, e.g. a tenant's namespace.
+	Namespace string
+	// Pods must have all the labels, empty means all pods in the namespace.
+	PodLabels map[string]string
+	// Security groups attached to the pods' branch ENIs instead of the nodes' security groups.
+	SecurityGroups []awsec2.ISecurityGroup
+}
+
+// Create SecurityGroupPolicies of 'cdk.json/context/podSecurityGroups', VPC CNI runs with ENABLE_POD_ENI.
+// Namespaces of tenants are created before the SecurityGroupPolicies in them.
+func NewEksPodSecurityGroups(stack awscdk.Stack, cluster awseks.Cluster, namespaces map[string]awseks.KubernetesManifest) {
+	for _, policy := range config.PodSecurityGroups(stack).Policies {
+		id := "SecurityGroupPolicy-" + policy.Namespace + "-" + policy.Name
+		var securityGroups []awsec2.ISecurityGroup
+		for _, securityGroupId := range policy.SecurityGroupIds {
+			securityGroups = append(securityGroups, awsec2.SecurityGroup_FromSecurityGroupId(stack,
+				jsii.String(id+"-"+securityGroupId), jsii.String(securityGroupId), &awsec2.SecurityGroupImportOptions{
+					Mutable: jsii.Bool(true),
+				}))
+		}
+
+		securityGroupPolicy := NewSecurityGroupPolicy(stack, cluster, &SecurityGroupPolicyProps{
+			Name:           policy.Name,
+			Namespace:      policy.Namespace,
+			PodLabels:      policy.PodLabels,
+			SecurityGroups: securityGroups,
+		})
+		if namespace, ok := namespaces[policy.Namespace]; ok && securityGroupPolicy != nil {
+			securityGroupPolicy.Node().AddDependency(namespace)
+		}
+	}
+}
+
+// Create a SecurityGroupPolicy, pods get their own security groups, e.g. clients of Redis and Aurora.
+// The security groups are allowed to receive kubelet probes from nodes and to query CoreDNS.
+func NewSecurityGroupPolicy(stack awscdk.Stack, cluster awseks.Cluster, props *SecurityGroupPolicyProps) awseks.KubernetesManifest {
+	if len(props.SecurityGroups) == 0 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"SecurityGroupPolicy %s/%s has no security groups, set its securityGroupIds.", props.Namespace, props.Name)))
+		return nil
+	}
+
+	var groupIds []*string
+	for _, securityGroup := range props.SecurityGroups {
+		groupIds = append(groupIds, securityGroup.SecurityGroupId())
+		// Nodes' and cluster's security groups, CoreDNS runs on nodes or Fargate.
+		securityGroup.Connections().AllowFrom(cluster, awsec2.Port_AllTcp(),
+			jsii.String("Allow kubelet probes from nodes."))
+		securityGroup.Connections().AllowTo(cluster, awsec2.Port_Tcp(jsii.Number(53)),
+			jsii.String("Allow DNS queries to CoreDNS."))
+		securityGroup.Connections().AllowTo(cluster, awsec2.Port_Udp(jsii.Number(53)),
+			jsii.String("Allow DNS queries to CoreDNS."))
+	}
+
+	podSelector := map[string]interface{}{}
+	if len(props.PodLabels) > 0 {
+		podSelector["matchLabels"] = props.PodLabels
+	}
+
+	return cluster.AddManifest(jsii.String("SecurityGroupPolicy-"+props.Namespace+"-"+props.Name), &map[string]interface{}{
+		"apiVersion": "vpcresources.k8s.aws/v1beta1",
+		"kind":       "SecurityGroupPolicy",
+		"metadata": map[string]interface{}{
+			"name":      props.Name,
+			"namespace": props.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"podSelector": podSelector,
+			"securityGroups": map[string]interface{}{
+				"groupIds": groupIds,
+			},
+		},
+	})
+}
@@ -0,0 +1,309 @@
+package addons
+
+import (
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Namespaces of the system and addons, their images come from public registries and some of them are privileged.
+var policyExcludedNamespaces = []string{
+	"kube-system", "kube-public", "kube-node-lease",
+	"kyverno", "gatekeeper-system",
+	"cert-manager", "monitoring", "opentelemetry", "velero", "argocd", "flux-system",
+}
+
+// Install a policy engine, Kyverno or Gatekeeper, with baseline policies of 'cdk.json/context/policyEngine':
+// no privileged containers, CPU and memory limits on every container, images from allowed registries only.
+// Violations are audited in DEV stage and rejected in PROD stage.
+// LimitRanges of tenants' namespaces set default limits before the policies validate pods.
+func NewEksPolicyEngine(stack awscdk.Stack, cluster awseks.Cluster) {
+	policyEngine := config.PolicyEngine(stack)
+	enforce := config.DeploymentStage(stack) == config.DeploymentStage_PROD
+
+	allowedRegistries := policyEngine.AllowedRegistries
+	if len(allowedRegistries) == 0 {
+		allowedRegistries = []string{*stack.Account() + ".dkr.ecr." + *stack.Region() + "." + *stack.UrlSuffix() + "/"}
+	}
+	excludedNamespaces := append(append([]string{}, policyExcludedNamespaces...), policyEngine.ExcludedNamespaces...)
+
+	switch policyEngine.Engine {
+	case config.PolicyEngine_KYVERNO:
+		newKyvernoPolicies(stack, cluster, enforce, allowedRegistries, excludedNamespaces)
+	case config.PolicyEngine_GATEKEEPER:
+		newGatekeeperPolicies(stack, cluster, enforce, allowedRegistries, excludedNamespaces)
+	default:
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Policy engine %s is not valid, valid values are: kyverno, gatekeeper.", policyEngine.Engine)))
+	}
+}
+
+// https://github.com/kyverno/kyverno/tree/main/charts/kyverno
+// Rules of Pods are also applied to their controllers, e.g. Deployments, by Kyverno's auto-gen.
+func newKyvernoPolicies(stack awscdk.Stack, cluster awseks.Cluster, enforce bool, allowedRegistries []string, excludedNamespaces []string) {
+	kyvernoChart := awseks.NewHelmChart(stack, jsii.String("KyvernoChart"), &awseks.HelmChartProps{
+		Repository:      jsii.String("https://kyverno.github.io/kyverno"),
+		Release:         jsii.String("kyverno"),
+		Cluster:         cluster,
+		Chart:           jsii.String("kyverno"),
+		Namespace:       jsii.String("kyverno"),
+		CreateNamespace: jsii.Bool(true),
+		Wait:            jsii.Bool(true),
+		Version:         jsii.String(config.ChartVersion(stack, "kyverno")),
+	})
+
+	validationFailureAction := "Audit"
+	if enforce {
+		validationFailureAction = "Enforce"
+	}
+	// Images of all containers must match one of the registries' patterns.
+	var imagePatterns []string
+	for _, registry := range allowedRegistries {
+		imagePatterns = append(imagePatterns, registry+"*")
+	}
+	imagePattern := strings.Join(imagePatterns, " | ")
+
+	policies := []struct {
+		name    string
+		message string
+		pattern map[string]interface{}
+	}{
+		{
+			name:    "disallow-privileged-containers",
+			message: "Privileged containers are not allowed.",
+			pattern: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"=(ephemeralContainers)": []interface{}{map[string]interface{}{"=(securityContext)": map[string]interface{}{"=(privileged)": "false"}}},
+					"=(initContainers)":      []interface{}{map[string]interface{}{"=(securityContext)": map[string]interface{}{"=(privileged)": "false"}}},
+					"containers":             []interface{}{map[string]interface{}{"=(securityContext)": map[string]interface{}{"=(privileged)": "false"}}},
+				},
+			},
+		},
+		{
+			name:    "require-resource-limits",
+			message: "CPU and memory limits are required.",
+			pattern: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{
+						"resources": map[string]interface{}{
+							"limits": map[string]interface{}{
+								"cpu":    "?*",
+								"memory": "?*",
+							},
+						},
+					}},
+				},
+			},
+		},
+		{
+			name:    "restrict-image-registries",
+			message: "Images must come from the allowed registries: " + strings.Join(allowedRegistries, ", "),
+			pattern: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"=(ephemeralContainers)": []interface{}{map[string]interface{}{"image": imagePattern}},
+					"=(initContainers)":      []interface{}{map[string]interface{}{"image": imagePattern}},
+					"containers":             []interface{}{map[string]interface{}{"image": imagePattern}},
+				},
+			},
+		},
+	}
+
+	for _, policy := range policies {
+		clusterPolicy := cluster.AddManifest(jsii.String("KyvernoPolicy-"+policy.name), &map[string]interface{}{
+			"apiVersion": "kyverno.io/v1",
+			"kind":       "ClusterPolicy",
+			"metadata": map[string]interface{}{
+				"name": policy.name,
+			},
+			"spec": map[string]interface{}{
+				"validationFailureAction": validationFailureAction,
+				"background":              true,
+				"rules": []interface{}{
+					map[string]interface{}{
+						"name": policy.name,
+						"match": map[string]interface{}{
+							"any": []interface{}{
+								map[string]interface{}{"resources": map[string]interface{}{"kinds": []string{"Pod"}}},
+							},
+						},
+						"exclude": map[string]interface{}{
+							"any": []interface{}{
+								map[string]interface{}{"resources": map[string]interface{}{"namespaces": excludedNamespaces}},
+							},
+						},
+						"validate": map[string]interface{}{
+							"message": policy.message,
+							"pattern": policy.pattern,
+						},
+					},
+				},
+			},
+		})
+		clusterPolicy.Node().AddDependency(kyvernoChart)
+	}
+}
+
+// https://github.com/open-policy-agent/gatekeeper/tree/master/charts/gatekeeper
+// Templates are simplified from https://github.com/open-policy-agent/gatekeeper-library.
+func newGatekeeperPolicies(stack awscdk.Stack, cluster awseks.Cluster, enforce bool, allowedRegistries []string, excludedNamespaces []string) {
+	gatekeeperChart := awseks.NewHelmChart(stack, jsii.String("GatekeeperChart"), &awseks.HelmChartProps{
+		Repository:      jsii.String("https://open-policy-agent.github.io/gatekeeper/charts"),
+		Release:         jsii.String("gatekeeper"),
+		Cluster:         cluster,
+		Chart:           jsii.String("gatekeeper"),
+		Namespace:       jsii.String("gatekeeper-system"),
+		CreateNamespace: jsii.Bool(true),
+		Wait:            jsii.Bool(true),
+		Version:         jsii.String(config.ChartVersion(stack, "gatekeeper")),
+	})
+
+	enforcementAction := "dryrun"
+	if enforce {
+		enforcementAction = "deny"
+	}
+	// Containers of all kinds in a Pod.
+	inputContainers := `
+input_containers[c] {
+  c := input.review.object.spec.containers[_]
+}
+input_containers[c] {
+  c := input.review.object.spec.initContainers[_]
+}
+input_containers[c] {
+  c := input.review.object.spec.ephemeralContainers[_]
+}
+`
+
+	templates := []struct {
+		kind       string
+		rego       string
+		parameters map[string]interface{}
+		schema     map[string]interface{}
+	}{
+		{
+			kind: "K8sDisallowPrivilegedContainers",
+			rego: `package k8sdisallowprivilegedcontainers
+
+violation[{"msg": msg}] {
+  c := input_containers[_]
+  c.securityContext.privileged
+  msg := sprintf("Privileged container %v is not allowed.", [c.name])
+}
+` + inputContainers,
+		},
+		{
+			kind: "K8sRequireResourceLimits",
+			rego: `package k8srequireresourcelimits
+
+violation[{"msg": msg}] {
+  c := input.review.object.spec.containers[_]
+  not c.resources.limits.cpu
+  msg := sprintf("Container %v has no CPU limit.", [c.name])
+}
+violation[{"msg": msg}] {
+  c := input.review.object.spec.containers[_]
+  not c.resources.limits.memory
+  msg := sprintf("Container %v has no memory limit.", [c.name])
+}
+`,
+		},
+		{
+			kind: "K8sRestrictImageRegistries",
+			rego: `package k8srestrictimageregistries
+
+violation[{"msg": msg}] {
+  c := input_containers[_]
+  not allowed(c.image)
+  msg := sprintf("Image %v of container %v is not from the allowed registries: %v.", [c.image, c.name, input.parameters.registries])
+}
+allowed(image) {
+  startswith(image, input.parameters.registries[_])
+}
+` + inputContainers,
+			parameters: map[string]interface{}{
+				"registries": allowedRegistries,
+			},
+			schema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"registries": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, template := range templates {
+		name := strings.ToLower(template.kind)
+		crdSpec := map[string]interface{}{
+			"names": map[string]interface{}{
+				"kind": template.kind,
+			},
+		}
+		if template.schema != nil {
+			crdSpec["validation"] = map[string]interface{}{
+				"openAPIV3Schema": template.schema,
+			}
+		}
+		constraintTemplate := cluster.AddManifest(jsii.String("GatekeeperTemplate-"+name), &map[string]interface{}{
+			"apiVersion": "templates.gatekeeper.sh/v1beta1",
+			"kind":       "ConstraintTemplate",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+			"spec": map[string]interface{}{
+				"crd": map[string]interface{}{
+					"spec": crdSpec,
+				},
+				"targets": []interface{}{
+					map[string]interface{}{
+						"target": "admission.k8s.gatekeeper.sh",
+						"rego":   template.rego,
+					},
+				},
+			},
+		})
+		constraintTemplate.Node().AddDependency(gatekeeperChart)
+
+		constraintSpec := map[string]interface{}{
+			"enforcementAction": enforcementAction,
+			"match": map[string]interface{}{
+				"kinds": []interface{}{
+					map[string]interface{}{
+						"apiGroups": []string{""},
+						"kinds":     []string{"Pod"},
+					},
+				},
+				"excludedNamespaces": excludedNamespaces,
+			},
+		}
+		if template.parameters != nil {
+			constraintSpec["parameters"] = template.parameters
+		}
+		// Gatekeeper creates the constraint's CRD from the template asynchronously,
+		// the constraint is applied once the template reports status.created.
+		templateCreated := awseks.NewKubernetesObjectValue(stack, jsii.String("GatekeeperTemplateCreated-"+name), &awseks.KubernetesObjectValueProps{
+			Cluster:    cluster,
+			ObjectType: jsii.String("constrainttemplate"),
+			ObjectName: jsii.String(name),
+			JsonPath:   jsii.String(".status.created"),
+		})
+		templateCreated.Node().AddDependency(constraintTemplate)
+		constraint := cluster.AddManifest(jsii.String("GatekeeperConstraint-"+name), &map[string]interface{}{
+			"apiVersion": "constraints.gatekeeper.sh/v1beta1",
+			"kind":       template.kind,
+			"metadata": map[string]interface{}{
+				"name": "baseline",
+			},
+			"spec": constraintSpec,
+		})
+		constraint.Node().AddDependency(templateCreated)
+	}
+}
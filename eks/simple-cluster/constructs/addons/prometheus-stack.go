@@ -0,0 +1,253 @@
+package addons
+
+import (
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsaps"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install kube-prometheus-stack, Prometheus and Grafana keep their data on EBS volumes of the gp3 StorageClass.
+// Prometheus optionally remote writes metrics to Amazon Managed Service for Prometheus (AMP),
+// Grafana is exposed by an internal ALB of AWS Load Balancer Controller, its admin password is generated in Secrets Manager.
+func NewEksPrometheusStack(stack awscdk.Stack, cluster awseks.Cluster, storageClass awseks.KubernetesManifest, lbcChart awseks.HelmChart) {
+	prometheusStack := config.PrometheusStack(stack)
+	namespace := "monitoring"
+
+	monitoringNamespace := cluster.AddManifest(jsii.String("MonitoringNamespace"), &map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": namespace,
+		},
+	})
+
+	prometheusValues := map[string]interface{}{
+		"retention": prometheusStack.Retention,
+		"storageSpec": map[string]interface{}{
+			"volumeClaimTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"storageClassName": gp3StorageClass,
+					"accessModes":      []string{"ReadWriteOnce"},
+					"resources": map[string]interface{}{
+						"requests": map[string]string{
+							"storage": prometheusStack.StorageSize,
+						},
+					},
+				},
+			},
+		},
+	}
+	prometheusServiceAccount := map[string]interface{}{
+		"create": true,
+	}
+
+	var prometheusSa awseks.ServiceAccount = nil
+	if prometheusStack.RemoteWrite {
+		var workspaceArn, remoteWriteUrl *string
+		if len(prometheusStack.WorkspaceId) > 0 {
+			workspaceArn, remoteWriteUrl = ampWorkspace(stack, prometheusStack.WorkspaceId)
+		} else {
+			workspace := awsaps.NewCfnWorkspace(stack, jsii.String("PrometheusWorkspace"), &awsaps.CfnWorkspaceProps{
+				Alias: jsii.String(*stack.StackName()),
+			})
+			workspaceArn = workspace.AttrArn()
+			// Prometheus endpoint ends with '/'.
+			remoteWriteUrl = jsii.String(*workspace.AttrPrometheusEndpoint() + "api/v1/remote_write")
+
+			awscdk.NewCfnOutput(stack, jsii.String("prometheusWorkspaceId"), &awscdk.CfnOutputProps{
+				Value: workspace.AttrWorkspaceId(),
+			})
+		}
+
+		prometheusSa = newServiceAccount(stack, cluster, "PrometheusSA", namespace, "prometheus")
+		prometheusSa.Node().AddDependency(monitoringNamespace)
+		prometheusSa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("aps:RemoteWrite"),
+			},
+			Resources: &[]*string{
+				workspaceArn,
+			},
+		}))
+
+		prometheusValues["remoteWrite"] = []interface{}{
+			map[string]interface{}{
+				"url": remoteWriteUrl,
+				"sigv4": map[string]interface{}{
+					"region": stack.Region(),
+				},
+				"queueConfig": map[string]interface{}{
+					"maxSamplesPerSend": 1000,
+					"maxShards":         200,
+					"capacity":          2500,
+				},
+			},
+		}
+		prometheusServiceAccount = map[string]interface{}{
+			"create": false,
+			"name":   prometheusSa.ServiceAccountName(),
+		}
+	}
+
+	grafanaAdmin := newGrafanaAdminSecret(stack, cluster, namespace)
+	grafanaAdmin.Node().AddDependency(monitoringNamespace)
+
+	// Internal ALB is placed in private subnets (isolated in none+endpoints NAT mode), or public subnets if the VPC has no private subnets (DEV stage).
+	albSubnets := cluster.Vpc().PrivateSubnets()
+	if len(*albSubnets) == 0 {
+		albSubnets = cluster.Vpc().IsolatedSubnets()
+	}
+	if len(*albSubnets) == 0 {
+		albSubnets = cluster.Vpc().PublicSubnets()
+	}
+	var albSubnetIds []string
+	for _, subnet := range *albSubnets {
+		albSubnetIds = append(albSubnetIds, *subnet.SubnetId())
+	}
+
+	// https://github.com/prometheus-community/helm-charts/tree/main/charts/kube-prometheus-stack
+	prometheusChart := awseks.NewHelmChart(stack, jsii.String("PrometheusStackChart"), &awseks.HelmChartProps{
+		Repository:      jsii.String("https://prometheus-community.github.io/helm-charts"),
+		Release:         jsii.String("kube-prometheus-stack"),
+		Cluster:         cluster,
+		Chart:           jsii.String("kube-prometheus-stack"),
+		Namespace:       jsii.String(namespace),
+		CreateNamespace: jsii.Bool(true),
+		Wait:            jsii.Bool(true),
+		Timeout:         awscdk.Duration_Minutes(jsii.Number(15)),
+		Version:         jsii.String(config.ChartVersion(stack, "kube-prometheus-stack")),
+		Values: &map[string]interface{}{
+			// Control plane components are managed by EKS, their metrics endpoints are not reachable.
+			"kubeControllerManager": map[string]interface{}{
+				"enabled": false,
+			},
+			"kubeScheduler": map[string]interface{}{
+				"enabled": false,
+			},
+			"kubeEtcd": map[string]interface{}{
+				"enabled": false,
+			},
+			// kube-proxy of EKS binds its metrics endpoint to 127.0.0.1.
+			"kubeProxy": map[string]interface{}{
+				"enabled": false,
+			},
+			"prometheus": map[string]interface{}{
+				"serviceAccount": prometheusServiceAccount,
+				"prometheusSpec": prometheusValues,
+			},
+			"grafana": map[string]interface{}{
+				// Never keep the chart's default admin password.
+				"admin": map[string]interface{}{
+					"existingSecret": grafanaAdminSecretName,
+					"userKey":        "admin-user",
+					"passwordKey":    "admin-password",
+				},
+				"persistence": map[string]interface{}{
+					"enabled":          true,
+					"storageClassName": gp3StorageClass,
+					"size":             prometheusStack.GrafanaStorageSize,
+				},
+				// EBS volume can't be attached to the old and the new pod at the same time.
+				"deploymentStrategy": map[string]interface{}{
+					"type": "Recreate",
+				},
+				"ingress": map[string]interface{}{
+					"enabled":          true,
+					"ingressClassName": "alb",
+					"hosts":            []string{},
+					"path":             "/",
+					"annotations": map[string]string{
+						"alb.ingress.kubernetes.io/scheme":           "internal",
+						"alb.ingress.kubernetes.io/target-type":      "ip",
+						"alb.ingress.kubernetes.io/subnets":          strings.Join(albSubnetIds, ","),
+						"alb.ingress.kubernetes.io/inbound-cidrs":    config.VpcCidr,
+						"alb.ingress.kubernetes.io/healthcheck-path": "/api/health",
+					},
+				},
+			},
+		},
+	})
+	prometheusChart.Node().AddDependency(monitoringNamespace)
+	prometheusChart.Node().AddDependency(storageClass)
+	prometheusChart.Node().AddDependency(grafanaAdmin)
+	// Ingress is validated by AWS Load Balancer Controller's webhook.
+	prometheusChart.Node().AddDependency(lbcChart)
+	if prometheusSa != nil {
+		prometheusChart.Node().AddDependency(prometheusSa)
+	}
+}
+
+// K8s secret of Grafana's admin user and password.
+const grafanaAdminSecretName = "grafana-admin"
+
+// Generate Grafana's admin password in Secrets Manager and sync it to the monitoring namespace by External Secrets Operator.
+// Get the password by: aws secretsmanager get-secret-value --secret-id <grafanaAdminSecretArn>
+func newGrafanaAdminSecret(stack awscdk.Stack, cluster awseks.Cluster, namespace string) awseks.KubernetesManifest {
+	secret := awssecretsmanager.NewSecret(stack, jsii.String("GrafanaAdminSecret"), &awssecretsmanager.SecretProps{
+		Description: jsii.String("Grafana admin of EKS cluster " + config.ClusterName(stack)),
+		GenerateSecretString: &awssecretsmanager.SecretStringGenerator{
+			SecretStringTemplate: jsii.String(`{"admin-user": "admin"}`),
+			GenerateStringKey:    jsii.String("admin-password"),
+			ExcludePunctuation:   jsii.Bool(true),
+			PasswordLength:       jsii.Number(32),
+		},
+	})
+	awscdk.NewCfnOutput(stack, jsii.String("grafanaAdminSecretArn"), &awscdk.CfnOutputProps{
+		Value: secret.SecretArn(),
+	})
+
+	esoChart, esoSa := newEksExternalSecrets(stack, cluster)
+	secret.GrantRead(esoSa.Role(), nil)
+
+	grafanaAdmin := cluster.AddManifest(jsii.String("GrafanaAdminCredentials"),
+		secretStore(stack, namespace),
+		&map[string]interface{}{
+			"apiVersion": "external-secrets.io/v1beta1",
+			"kind":       "ExternalSecret",
+			"metadata": map[string]interface{}{
+				"name":      grafanaAdminSecretName,
+				"namespace": namespace,
+			},
+			"spec": map[string]interface{}{
+				"refreshInterval": "1h",
+				"secretStoreRef": map[string]interface{}{
+					"name": "aws-secrets-manager",
+					"kind": "SecretStore",
+				},
+				"target": map[string]interface{}{
+					"name": grafanaAdminSecretName,
+				},
+				"dataFrom": []interface{}{
+					map[string]interface{}{
+						"extract": map[string]interface{}{
+							"key": secret.SecretName(),
+						},
+					},
+				},
+			},
+		})
+	grafanaAdmin.Node().AddDependency(esoChart)
+
+	return grafanaAdmin
+}
+
+// ARN and remote write URL of an existing AMP workspace.
+func ampWorkspace(stack awscdk.Stack, workspaceId string) (*string, *string) {
+	workspaceArn := stack.FormatArn(&awscdk.ArnComponents{
+		Service:      jsii.String("aps"),
+		Resource:     jsii.String("workspace"),
+		ResourceName: jsii.String(workspaceId),
+	})
+	remoteWriteUrl := jsii.String("https://aps-workspaces." + *stack.Region() + "." + *stack.UrlSuffix() +
+		"/workspaces/" + workspaceId + "/api/v1/remote_write")
+
+	return workspaceArn, remoteWriteUrl
+}
@@ -0,0 +1,198 @@
+package addons
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// SecretProviderClass properties
+type SecretProviderClassProps struct {
+	Name string
+	// Namespace must exist, e.g. a tenant's namespace.
+	Namespace string
+	// K8s service account of the pods that mount the secrets, it's created by NewSecretProviderClass.
+	ServiceAccountName string
+	// Complete ARNs of Secrets Manager secrets or SSM parameters.
+	SecretArns []string
+	// Region of the secrets, empty means the stack's region.
+	Region string
+}
+
+// Install Secrets Store CSI driver with AWS provider, and SecretProviderClasses of 'cdk.json/context/secretsStore'.
+// Namespaces of tenants are created before the SecretProviderClasses in them.
+func NewEksSecretsStore(stack awscdk.Stack, cluster awseks.Cluster, namespaces map[string]awseks.KubernetesManifest) {
+	newEksSecretsStoreCsiDriver(stack, cluster)
+
+	for _, spc := range config.SecretsStore(stack).SecretProviderClasses {
+		sa, secretProviderClass := NewSecretProviderClass(stack, cluster, &SecretProviderClassProps{
+			Name:               spc.Name,
+			Namespace:          spc.Namespace,
+			ServiceAccountName: spc.ServiceAccount,
+			SecretArns:         spc.SecretArns,
+			Region:             spc.Region,
+		})
+		if namespace, ok := namespaces[spc.Namespace]; ok && sa != nil {
+			sa.Node().AddDependency(namespace)
+			secretProviderClass.Node().AddDependency(namespace)
+		}
+	}
+}
+
+// Create a service account whose IAM role can only read the secrets, and a SecretProviderClass of the secrets.
+// Pods mount the secrets by a CSI volume of driver secrets-store.csi.k8s.io with volumeAttributes.secretProviderClass.
+func NewSecretProviderClass(stack awscdk.Stack, cluster awseks.Cluster, props *SecretProviderClassProps) (awseks.ServiceAccount, awseks.KubernetesManifest) {
+	if len(props.SecretArns) == 0 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"SecretProviderClass %s/%s has no secrets, set its secretArns.", props.Namespace, props.Name)))
+		return nil, nil
+	}
+
+	// The AWS provider reads all secrets of a SecretProviderClass from its region.
+	region := props.Region
+	if len(region) == 0 {
+		region = *stack.Region()
+	}
+
+	var secretArns, parameterArns []*string
+	var objects []interface{}
+	for _, arn := range props.SecretArns {
+		// arn:<partition>:secretsmanager:<region>:<account>:secret:<name>-<6 random characters>
+		// arn:<partition>:ssm:<region>:<account>:parameter/<name without leading '/'>
+		arnParts := strings.SplitN(arn, ":", 7)
+		if len(arnParts) >= 6 && arnParts[3] != region && !*awscdk.Token_IsUnresolved(jsii.String(region)) {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Secret ARN %s of SecretProviderClass %s/%s is not in region %s, set the region of the class or split it by region.",
+				arn, props.Namespace, props.Name, region)))
+			continue
+		}
+		switch {
+		case len(arnParts) == 7 && arnParts[2] == "secretsmanager" && arnParts[5] == "secret" && strings.Contains(arnParts[6], "-"):
+			secretArns = append(secretArns, jsii.String(arn))
+			objects = append(objects, map[string]string{
+				"objectName":  arn,
+				"objectType":  "secretsmanager",
+				"objectAlias": strings.ReplaceAll(arnParts[6][:strings.LastIndex(arnParts[6], "-")], "/", "_"),
+			})
+		case len(arnParts) == 6 && arnParts[2] == "ssm" && strings.HasPrefix(arnParts[5], "parameter/"):
+			parameterArns = append(parameterArns, jsii.String(arn))
+			// SSM provider only accepts parameter names, hierarchical names start with '/'.
+			name := strings.TrimPrefix(arnParts[5], "parameter")
+			if strings.Count(name, "/") == 1 {
+				name = strings.TrimPrefix(name, "/")
+			}
+			objects = append(objects, map[string]string{
+				"objectName":  name,
+				"objectType":  "ssmparameter",
+				"objectAlias": strings.ReplaceAll(strings.TrimPrefix(name, "/"), "/", "_"),
+			})
+		default:
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"Secret ARN %s of SecretProviderClass %s/%s is not a complete ARN of a Secrets Manager secret or an SSM parameter.",
+				arn, props.Namespace, props.Name)))
+		}
+	}
+
+	providerChart := newEksSecretsStoreCsiDriver(stack, cluster)
+	id := "SecretProviderClass-" + props.Namespace + "-" + props.Name
+
+	sa := newServiceAccount(stack, cluster, id+"SA", props.Namespace, props.ServiceAccountName)
+	if len(secretArns) > 0 {
+		sa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("secretsmanager:GetSecretValue"),
+			},
+			Resources: &secretArns,
+		}))
+	}
+	if len(parameterArns) > 0 {
+		sa.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("ssm:GetParameters"),
+			},
+			Resources: &parameterArns,
+		}))
+	}
+
+	// Objects are mounted as files named by objectAlias.
+	// They are YAML in a string, JSON is valid YAML.
+	objectsJson, err := json.Marshal(objects)
+	if err != nil {
+		panic(err)
+	}
+	parameters := map[string]string{
+		"region":  region,
+		"objects": string(objectsJson),
+	}
+	if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+		parameters["usePodIdentity"] = "true"
+	}
+
+	secretProviderClass := cluster.AddManifest(jsii.String(id), &map[string]interface{}{
+		"apiVersion": "secrets-store.csi.x-k8s.io/v1",
+		"kind":       "SecretProviderClass",
+		"metadata": map[string]interface{}{
+			"name":      props.Name,
+			"namespace": props.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"provider":   "aws",
+			"parameters": parameters,
+		},
+	})
+	secretProviderClass.Node().AddDependency(providerChart)
+
+	return sa, secretProviderClass
+}
+
+// Install Secrets Store CSI driver and its AWS provider once, it returns the provider's chart.
+func newEksSecretsStoreCsiDriver(stack awscdk.Stack, cluster awseks.Cluster) awseks.HelmChart {
+	if chart, ok := stack.Node().TryFindChild(jsii.String("SecretsStoreAWSProviderChart")).(awseks.HelmChart); ok {
+		return chart
+	}
+
+	// https://github.com/kubernetes-sigs/secrets-store-csi-driver/tree/main/charts/secrets-store-csi-driver
+	driverChart := awseks.NewHelmChart(stack, jsii.String("SecretsStoreCSIDriverChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://kubernetes-sigs.github.io/secrets-store-csi-driver/charts"),
+		Release:    jsii.String("secrets-store-csi-driver"),
+		Cluster:    cluster,
+		Chart:      jsii.String("secrets-store-csi-driver"),
+		Namespace:  jsii.String("kube-system"),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(config.ChartVersion(stack, "secrets-store-csi-driver")),
+		Values: &map[string]interface{}{
+			// Mounted secrets follow the changes in Secrets Manager and SSM.
+			"enableSecretRotation": jsii.Bool(true),
+			"rotationPollInterval": jsii.String("2m"),
+			"linux": map[string]interface{}{
+				"tolerations": spotTolerations,
+			},
+		},
+	})
+
+	// https://github.com/aws/secrets-store-csi-driver-provider-aws/tree/main/charts/secrets-store-csi-driver-provider-aws
+	providerChart := awseks.NewHelmChart(stack, jsii.String("SecretsStoreAWSProviderChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://aws.github.io/secrets-store-csi-driver-provider-aws"),
+		Release:    jsii.String("secrets-store-csi-driver-provider-aws"),
+		Cluster:    cluster,
+		Chart:      jsii.String("secrets-store-csi-driver-provider-aws"),
+		Namespace:  jsii.String("kube-system"),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(config.ChartVersion(stack, "secrets-store-csi-driver-provider-aws")),
+		Values: &map[string]interface{}{
+			"tolerations": spotTolerations,
+		},
+	})
+	providerChart.Node().AddDependency(driverChart)
+
+	return providerChart
+}
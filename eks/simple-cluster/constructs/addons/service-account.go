@@ -0,0 +1,68 @@
+package addons
+
+import (
+	"fmt"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install EKS Pod Identity Agent add-on.
+// The add-on is owned by the cluster, so it's installed only once no matter how many service accounts use it.
+func NewEksPodIdentityAgent(stack awscdk.Stack, cluster awseks.Cluster) awseks.IAddon {
+	if config.KubernetesMinorVersion(stack) < 24 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"EKS Pod Identity requires Kubernetes 1.24 or later, but the cluster version is %s.", config.KubernetesVersion(stack))))
+	}
+
+	return cluster.EksPodIdentityAgent()
+}
+
+// Create K8s service account with an IAM role bound to it.
+// The role is bound by IRSA or EKS Pod Identity according to 'cdk.json/context/serviceAccountIdentity'.
+func newServiceAccount(stack awscdk.Stack, cluster awseks.Cluster, id string, namespace string, name string) awseks.ServiceAccount {
+	identityType := awseks.IdentityType_IRSA
+	if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+		identityType = awseks.IdentityType_POD_IDENTITY
+	}
+
+	sa := awseks.NewServiceAccount(stack, jsii.String(id), &awseks.ServiceAccountProps{
+		Name:         jsii.String(name),
+		Cluster:      cluster,
+		Namespace:    jsii.String(namespace),
+		IdentityType: identityType,
+	})
+	if identityType == awseks.IdentityType_POD_IDENTITY {
+		sa.Node().AddDependency(cluster.EksPodIdentityAgent())
+	}
+
+	return sa
+}
+
+// Create IAM role for a service account that is created by others, e.g. EKS managed add-ons.
+// The role trusts the cluster's OIDC provider (IRSA) or the EKS Pod Identity service principal,
+// the owner of the service account is responsible for the binding.
+func newServiceAccountRole(stack awscdk.Stack, cluster awseks.Cluster, id string, roleName string, namespace string, name string) awsiam.Role {
+	var principal awsiam.IPrincipal
+	if config.ServiceAccountIdentity(stack) == config.ServiceAccountIdentity_POD_IDENTITY {
+		principal = awsiam.NewSessionTagsPrincipal(awsiam.NewServicePrincipal(jsii.String("pods.eks.amazonaws.com"), nil))
+	} else {
+		principal = awsiam.NewWebIdentityPrincipal(cluster.OpenIdConnectProvider().OpenIdConnectProviderArn(), &map[string]interface{}{
+			"StringEquals": awscdk.NewCfnJson(stack, jsii.String("CfnJson-"+id), &awscdk.CfnJsonProps{
+				Value: map[string]string{
+					*cluster.OpenIdConnectProvider().OpenIdConnectProviderIssuer() + ":aud": "sts.amazonaws.com",
+					*cluster.OpenIdConnectProvider().OpenIdConnectProviderIssuer() + ":sub": "system:serviceaccount:" + namespace + ":" + name,
+				},
+			}),
+		})
+	}
+
+	return awsiam.NewRole(stack, jsii.String(id), &awsiam.RoleProps{
+		RoleName:  jsii.String(*stack.StackName() + "-" + *stack.Region() + "-" + roleName),
+		AssumedBy: principal,
+	})
+}
@@ -0,0 +1,239 @@
+package addons
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"simple-cluster/config"
+	"simple-cluster/constructs/access"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Create tenants' namespaces with quotas, network isolation, RBAC and service account roles.
+// Tenants' IAM roles access the cluster by access entries of K8s groups, which are bound to ClusterRoles in their namespaces.
+// It returns tenants' namespace manifests keyed by namespace, resources in these namespaces must depend on them.
+func NewEksTenants(stack awscdk.Stack, cluster awseks.Cluster) map[string]awseks.KubernetesManifest {
+	tenants := config.Tenants(stack)
+	if len(tenants) > 0 && config.KubernetesMinorVersion(stack) < 25 {
+		awscdk.Annotations_Of(stack).AddWarning(jsii.String(
+			"VPC CNI enforces NetworkPolicies on Kubernetes 1.25 or later, tenants' namespaces are not isolated."))
+	}
+
+	// K8s groups of each IAM role keyed by access.PrincipalKey, an IAM role can only have one access entry.
+	roleGroups := map[string][]string{}
+	roleNames := map[string]string{}
+	namespaces := map[string]awseks.KubernetesManifest{}
+
+	for _, tenant := range tenants {
+		id := "Tenant-" + tenant.Name
+		checkTenantAllowedCidrs(stack, tenant)
+
+		namespace := cluster.AddManifest(jsii.String(id+"-Namespace"), &map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Namespace",
+			"metadata": map[string]interface{}{
+				"name": tenant.Name,
+				"labels": map[string]string{
+					"tenant": tenant.Name,
+				},
+			},
+		})
+		namespaces[tenant.Name] = namespace
+
+		manifests := []*map[string]interface{}{
+			tenantNetworkPolicy(tenant),
+		}
+		if len(tenant.Quota) > 0 {
+			manifests = append(manifests, &map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ResourceQuota",
+				"metadata": map[string]interface{}{
+					"name":      "tenant-quota",
+					"namespace": tenant.Name,
+				},
+				"spec": map[string]interface{}{
+					"hard": tenant.Quota,
+				},
+			})
+		}
+		if len(tenant.DefaultLimits) > 0 || len(tenant.DefaultRequests) > 0 {
+			limit := map[string]interface{}{
+				"type": "Container",
+			}
+			if len(tenant.DefaultLimits) > 0 {
+				limit["default"] = tenant.DefaultLimits
+			}
+			if len(tenant.DefaultRequests) > 0 {
+				limit["defaultRequest"] = tenant.DefaultRequests
+			}
+			manifests = append(manifests, &map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "LimitRange",
+				"metadata": map[string]interface{}{
+					"name":      "tenant-limits",
+					"namespace": tenant.Name,
+				},
+				"spec": map[string]interface{}{
+					"limits": []interface{}{limit},
+				},
+			})
+		}
+
+		bindings := map[string]bool{}
+		for _, role := range tenant.Roles {
+			if role.Access != "admin" && role.Access != "edit" && role.Access != "view" {
+				awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+					"Access %s of tenant %s role %s is not valid, valid values are: admin, edit, view.", role.Access, tenant.Name, role.Name)))
+				continue
+			}
+
+			group := tenant.Name + ":" + role.Access
+			roleKey := access.PrincipalKey("role", role.Name)
+			if _, ok := roleNames[roleKey]; !ok {
+				roleNames[roleKey] = role.Name
+			}
+			roleGroups[roleKey] = append(roleGroups[roleKey], group)
+			if bindings[role.Access] {
+				continue
+			}
+			bindings[role.Access] = true
+			manifests = append(manifests, &map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "RoleBinding",
+				"metadata": map[string]interface{}{
+					"name":      "tenant-" + role.Access,
+					"namespace": tenant.Name,
+				},
+				"roleRef": map[string]string{
+					"apiGroup": "rbac.authorization.k8s.io",
+					"kind":     "ClusterRole",
+					"name":     role.Access,
+				},
+				"subjects": []interface{}{
+					map[string]string{
+						"apiGroup": "rbac.authorization.k8s.io",
+						"kind":     "Group",
+						"name":     group,
+					},
+				},
+			})
+		}
+
+		resources := cluster.AddManifest(jsii.String(id+"-Resources"), manifests...)
+		resources.Node().AddDependency(namespace)
+
+		for _, serviceAccount := range tenant.ServiceAccounts {
+			sa := newServiceAccount(stack, cluster, id+"-"+serviceAccount.Name+"SA", tenant.Name, serviceAccount.Name)
+			sa.Node().AddDependency(namespace)
+			for i, policy := range serviceAccount.ManagedPolicies {
+				if strings.HasPrefix(policy, "arn:") {
+					sa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromManagedPolicyArn(stack,
+						jsii.String(fmt.Sprintf("%s-%sPolicy%d", id, serviceAccount.Name, i)), jsii.String(policy)))
+				} else {
+					sa.Role().AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String(policy)))
+				}
+			}
+		}
+	}
+
+	var roleKeys []string
+	for roleKey := range roleGroups {
+		roleKeys = append(roleKeys, roleKey)
+	}
+	sort.Strings(roleKeys)
+	for _, roleKey := range roleKeys {
+		roleName := roleNames[roleKey]
+		accessEntry := awseks.NewCfnAccessEntry(stack, jsii.String("TenantAccess-"+access.PrincipalId("role", roleName)), &awseks.CfnAccessEntryProps{
+			ClusterName:      cluster.ClusterName(),
+			PrincipalArn:     access.PrincipalArn(stack, "role", roleName),
+			KubernetesGroups: jsii.Strings(roleGroups[roleKey]...),
+			Type:             jsii.String("STANDARD"),
+		})
+		accessEntry.Node().AddDependency(cluster)
+	}
+
+	return namespaces
+}
+
+// Allowed CIDRs must not undo the isolation of the tenant's namespace.
+// Pods get IPs from the pod CIDR with custom networking, or from the VPC CIDR without it.
+func checkTenantAllowedCidrs(stack awscdk.Stack, tenant config.Tenant) {
+	podsCidr := config.VpcCidr
+	if podCidr := config.PodCidr(stack); len(podCidr) > 0 {
+		podsCidr = podCidr
+	}
+	_, podsNet, err := net.ParseCIDR(podsCidr)
+	if err != nil {
+		return
+	}
+	podsOnes, _ := podsNet.Mask.Size()
+
+	for _, cidr := range tenant.AllowedCidrs {
+		_, allowedNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"allowedCidrs %s of tenant %s is not a valid CIDR.", cidr, tenant.Name)))
+			continue
+		}
+		allowedOnes, _ := allowedNet.Mask.Size()
+		if allowedNet.Contains(podsNet.IP) && allowedOnes <= podsOnes || allowedOnes == 0 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"allowedCidrs %s of tenant %s contains all pods' IPs %s, pods of every namespace would reach the tenant.",
+				cidr, tenant.Name, podsCidr)))
+		} else if allowedNet.Contains(podsNet.IP) || podsNet.Contains(allowedNet.IP) {
+			awscdk.Annotations_Of(stack).AddWarning(jsii.String(fmt.Sprintf(
+				"allowedCidrs %s of tenant %s overlaps pods' IPs %s, pods of other namespaces in it reach the tenant. Set podCidr to separate pods from load balancers.",
+				cidr, tenant.Name, podsCidr)))
+		}
+	}
+}
+
+// Pods of a tenant only accept traffic from its own namespace, the allowed namespaces and the allowed CIDRs.
+// Load balancers targeting pods' IPs send traffic from their own VPC addresses, which are not pods.
+func tenantNetworkPolicy(tenant config.Tenant) *map[string]interface{} {
+	from := []interface{}{
+		map[string]interface{}{
+			"podSelector": map[string]interface{}{},
+		},
+	}
+	for _, cidr := range tenant.AllowedCidrs {
+		from = append(from, map[string]interface{}{
+			"ipBlock": map[string]interface{}{
+				"cidr": cidr,
+			},
+		})
+	}
+	for _, namespace := range tenant.AllowedNamespaces {
+		from = append(from, map[string]interface{}{
+			"namespaceSelector": map[string]interface{}{
+				"matchLabels": map[string]string{
+					"kubernetes.io/metadata.name": namespace,
+				},
+			},
+		})
+	}
+
+	return &map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "NetworkPolicy",
+		"metadata": map[string]interface{}{
+			"name":      "deny-cross-namespace",
+			"namespace": tenant.Name,
+		},
+		"spec": map[string]interface{}{
+			"podSelector": map[string]interface{}{},
+			"policyTypes": []string{"Ingress"},
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"from": from,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,326 @@
+package addons
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Install Velero, it backs up the cluster to a versioned S3 bucket by the schedule of 'cdk.json/context/velero'.
+// Volumes are backed up by EBS snapshots, or by file system backup of node agents if fsBackup is enabled.
+// https://github.com/vmware-tanzu/helm-charts/tree/main/charts/velero
+func NewEksVelero(stack awscdk.Stack, cluster awseks.Cluster) {
+	velero := config.Velero(stack)
+	namespace := "velero"
+
+	ttl, err := time.ParseDuration(velero.Ttl)
+	if err != nil {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Velero backup TTL %s is not valid, set velero/ttl to a duration, e.g. 720h.", velero.Ttl)))
+	} else if velero.ExpirationDays > 0 && time.Duration(velero.ExpirationDays)*24*time.Hour <= ttl {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Velero bucket expiration %d days must be longer than backup TTL %s, or backups are deleted before Velero expires them.",
+			velero.ExpirationDays, velero.Ttl)))
+	}
+	if len(velero.ReplicaRegion) > 0 && !velero.FsBackup {
+		awscdk.Annotations_Of(stack).AddWarning(jsii.String(
+			"EBS snapshots of Velero are not replicated to " + velero.ReplicaRegion + ", enable velero/fsBackup to restore volumes in that region."))
+	}
+
+	// Backups outlive the stack, the bucket is retained when the stack is deleted.
+	bucket := newVeleroBucket(stack, "VeleroBucket", nil, velero.ExpirationDays)
+	if len(velero.ReplicaRegion) > 0 {
+		addVeleroReplication(stack, bucket, VeleroReplicaBucketName(stack, velero.ReplicaRegion))
+	}
+
+	veleroNamespace := cluster.AddManifest(jsii.String("VeleroNamespace"), &map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": namespace,
+		},
+	})
+	veleroSa := newServiceAccount(stack, cluster, "VeleroSA", namespace, "velero-server")
+	veleroSa.Node().AddDependency(veleroNamespace)
+
+	// https://github.com/vmware-tanzu/velero-plugin-for-aws#set-permissions-for-velero
+	statements := []awsiam.PolicyStatement{
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("ec2:DescribeVolumes"),
+				jsii.String("ec2:DescribeSnapshots"),
+				jsii.String("ec2:CreateTags"),
+				jsii.String("ec2:CreateVolume"),
+				jsii.String("ec2:CreateSnapshot"),
+				jsii.String("ec2:DeleteSnapshot"),
+			},
+			Resources: &[]*string{
+				jsii.String("*"),
+			},
+		}),
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("s3:GetObject"),
+				jsii.String("s3:DeleteObject"),
+				jsii.String("s3:PutObject"),
+				jsii.String("s3:AbortMultipartUpload"),
+				jsii.String("s3:ListMultipartUploadParts"),
+			},
+			Resources: &[]*string{
+				bucket.ArnForObjects(jsii.String("*")),
+			},
+		}),
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("s3:ListBucket"),
+			},
+			Resources: &[]*string{
+				bucket.BucketArn(),
+			},
+		}),
+	}
+
+	backupStorageLocations := []interface{}{
+		map[string]interface{}{
+			"name":     "default",
+			"provider": "aws",
+			"bucket":   bucket.BucketName(),
+			"default":  true,
+			"config": map[string]interface{}{
+				"region": stack.Region(),
+			},
+		},
+	}
+	// Backups of another cluster are restored from the read-only location, Velero never writes or deletes them.
+	if len(velero.RestoreBucket) > 0 {
+		restoreBucket := awss3.Bucket_FromBucketName(stack, jsii.String("VeleroRestoreBucket"), jsii.String(velero.RestoreBucket))
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Effect: awsiam.Effect_ALLOW,
+			Actions: &[]*string{
+				jsii.String("s3:GetObject"),
+				jsii.String("s3:ListBucket"),
+			},
+			Resources: &[]*string{
+				restoreBucket.BucketArn(),
+				restoreBucket.ArnForObjects(jsii.String("*")),
+			},
+		}))
+		backupStorageLocations = append(backupStorageLocations, map[string]interface{}{
+			"name":       "restore",
+			"provider":   "aws",
+			"bucket":     velero.RestoreBucket,
+			"accessMode": "ReadOnly",
+			"config": map[string]interface{}{
+				"region": stack.Region(),
+			},
+		})
+	}
+
+	awsiam.NewPolicy(stack, jsii.String("VeleroPolicy"), &awsiam.PolicyProps{
+		PolicyName: jsii.String(*stack.StackName() + "-VeleroPolicy"),
+		Roles: &[]awsiam.IRole{
+			veleroSa.Role(),
+		},
+		Statements: &statements,
+	})
+
+	scheduleTemplate := map[string]interface{}{
+		"ttl":                      velero.Ttl,
+		"storageLocation":          "default",
+		"snapshotVolumes":          !velero.FsBackup,
+		"defaultVolumesToFsBackup": velero.FsBackup,
+	}
+	if len(velero.IncludedNamespaces) > 0 {
+		scheduleTemplate["includedNamespaces"] = velero.IncludedNamespaces
+	}
+
+	chartVersion := config.ChartVersion(stack, "velero")
+	veleroChart := awseks.NewHelmChart(stack, jsii.String("VeleroChart"), &awseks.HelmChartProps{
+		Repository: jsii.String("https://vmware-tanzu.github.io/helm-charts"),
+		Release:    jsii.String("velero"),
+		Cluster:    cluster,
+		Chart:      jsii.String("velero"),
+		Namespace:  jsii.String(namespace),
+		Wait:       jsii.Bool(true),
+		Version:    jsii.String(chartVersion),
+		Values: &map[string]interface{}{
+			"initContainers": []interface{}{
+				map[string]interface{}{
+					"name":            "velero-plugin-for-aws",
+					"image":           "velero/velero-plugin-for-aws:" + config.VeleroAwsPluginImageTags[chartVersion],
+					"imagePullPolicy": "IfNotPresent",
+					"volumeMounts": []interface{}{
+						map[string]interface{}{
+							"mountPath": "/target",
+							"name":      "plugins",
+						},
+					},
+				},
+			},
+			"configuration": map[string]interface{}{
+				"backupStorageLocation": backupStorageLocations,
+				"volumeSnapshotLocation": []interface{}{
+					map[string]interface{}{
+						"name":     "default",
+						"provider": "aws",
+						"config": map[string]interface{}{
+							"region": stack.Region(),
+						},
+					},
+				},
+			},
+			// Credentials come from the service account's IAM role.
+			"credentials": map[string]interface{}{
+				"useSecret": false,
+			},
+			"serviceAccount": map[string]interface{}{
+				"server": map[string]interface{}{
+					"create": false,
+					"name":   veleroSa.ServiceAccountName(),
+				},
+			},
+			"deployNodeAgent": velero.FsBackup,
+			"nodeAgent": map[string]interface{}{
+				"tolerations": spotTolerations,
+			},
+			"schedules": map[string]interface{}{
+				"default": map[string]interface{}{
+					"disabled": false,
+					"schedule": velero.Schedule,
+					// Backups outlive the schedule, e.g. when the chart is uninstalled.
+					"useOwnerReferencesInBackup": false,
+					"template":                   scheduleTemplate,
+				},
+			},
+		},
+	})
+	veleroChart.Node().AddDependency(veleroSa)
+
+	awscdk.NewCfnOutput(stack, jsii.String("veleroBucketName"), &awscdk.CfnOutputProps{
+		Value: bucket.BucketName(),
+	})
+}
+
+// Create the replica bucket of Velero in the replica region, the stack must be in that region.
+// It's created before the cluster's stack, which replicates its Velero bucket to it.
+func NewVeleroReplicaBucket(stack awscdk.Stack) {
+	velero := config.Velero(stack)
+	bucketName := VeleroReplicaBucketName(stack, *stack.Region())
+	bucket := newVeleroBucket(stack, "VeleroReplicaBucket", jsii.String(bucketName), velero.ExpirationDays)
+
+	awscdk.NewCfnOutput(stack, jsii.String("veleroReplicaBucketName"), &awscdk.CfnOutputProps{
+		Value: bucket.BucketName(),
+	})
+}
+
+// Name of the Velero replica bucket in the region, it's known before the bucket is created,
+// so the cluster's stack doesn't reference the replica bucket's stack across regions.
+func VeleroReplicaBucketName(stack awscdk.Stack, region string) string {
+	clusterName := strings.ReplaceAll(strings.ToLower(config.ClusterName(stack)), "_", "-")
+	bucketName := clusterName + "-velero-" + *stack.Account() + "-" + region
+	// Account of an environment-agnostic stack is a token, it's resolved at deploy time.
+	if !*awscdk.Token_IsUnresolved(jsii.String(bucketName)) && len(bucketName) > 63 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Velero replica bucket name %s is longer than 63 characters, use a shorter clusterName.", bucketName)))
+	}
+
+	return bucketName
+}
+
+// Versioned bucket of Velero, noncurrent versions are kept for a week to recover from deletion by mistake.
+func newVeleroBucket(stack awscdk.Stack, id string, bucketName *string, expirationDays int) awss3.Bucket {
+	lifecycleRule := &awss3.LifecycleRule{
+		AbortIncompleteMultipartUploadAfter: awscdk.Duration_Days(jsii.Number(1)),
+		NoncurrentVersionExpiration:         awscdk.Duration_Days(jsii.Number(7)),
+	}
+	if expirationDays > 0 {
+		lifecycleRule.Expiration = awscdk.Duration_Days(jsii.Number(float64(expirationDays)))
+	}
+
+	return awss3.NewBucket(stack, jsii.String(id), &awss3.BucketProps{
+		BucketName:        bucketName,
+		Versioned:         jsii.Bool(true),
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        jsii.Bool(true),
+		LifecycleRules:    &[]*awss3.LifecycleRule{lifecycleRule},
+		RemovalPolicy:     awscdk.RemovalPolicy_RETAIN,
+	})
+}
+
+// Replicate the Velero bucket to the replica bucket, deletions by Velero are not replicated,
+// the replica's lifecycle rule expires the backups instead.
+func addVeleroReplication(stack awscdk.Stack, bucket awss3.Bucket, replicaBucketName string) {
+	replicaBucketArn := jsii.String("arn:" + *stack.Partition() + ":s3:::" + replicaBucketName)
+
+	replicationRole := awsiam.NewRole(stack, jsii.String("VeleroReplicationRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("s3.amazonaws.com"), nil),
+	})
+	replicationRole.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("s3:GetReplicationConfiguration"),
+			jsii.String("s3:ListBucket"),
+		},
+		Resources: &[]*string{
+			bucket.BucketArn(),
+		},
+	}))
+	replicationRole.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("s3:GetObjectVersionForReplication"),
+			jsii.String("s3:GetObjectVersionAcl"),
+			jsii.String("s3:GetObjectVersionTagging"),
+		},
+		Resources: &[]*string{
+			bucket.ArnForObjects(jsii.String("*")),
+		},
+	}))
+	replicationRole.AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Effect: awsiam.Effect_ALLOW,
+		Actions: &[]*string{
+			jsii.String("s3:ReplicateObject"),
+			jsii.String("s3:ReplicateTags"),
+		},
+		Resources: &[]*string{
+			jsii.String(*replicaBucketArn + "/*"),
+		},
+	}))
+
+	// L2 Bucket of this CDK version has no replication rules.
+	cfnBucket := bucket.Node().DefaultChild().(awss3.CfnBucket)
+	cfnBucket.SetReplicationConfiguration(&awss3.CfnBucket_ReplicationConfigurationProperty{
+		Role: replicationRole.RoleArn(),
+		Rules: &[]interface{}{
+			&awss3.CfnBucket_ReplicationRuleProperty{
+				Id:       jsii.String("VeleroReplica"),
+				Status:   jsii.String("Enabled"),
+				Priority: jsii.Number(1),
+				Filter: &awss3.CfnBucket_ReplicationRuleFilterProperty{
+					Prefix: jsii.String(""),
+				},
+				DeleteMarkerReplication: &awss3.CfnBucket_DeleteMarkerReplicationProperty{
+					Status: jsii.String("Disabled"),
+				},
+				Destination: &awss3.CfnBucket_ReplicationDestinationProperty{
+					Bucket:       replicaBucketArn,
+					StorageClass: jsii.String("STANDARD_IA"),
+				},
+			},
+		},
+	})
+	// Replication configuration is validated with the role's policy when the bucket is updated.
+	cfnBucket.Node().AddDependency(replicationRole)
+}
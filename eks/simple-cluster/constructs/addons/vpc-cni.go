@@ -1,33 +1,141 @@
 package addons
 
 import (
+	"fmt"
+	"strconv"
+
+	"simple-cluster/config"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/jsii-runtime-go"
 )
 
 // Install VPC CNI add-on
-func NewEksVpcCni(stack awscdk.Stack, cluster awseks.Cluster) {
-	cniRole := awsiam.NewRole(stack, jsii.String("VPCCNIRole"), &awsiam.RoleProps{
-		RoleName: jsii.String(*stack.StackName() + "-" + *stack.Region() + "-AmazonEKSVPCCNIRole"),
-		AssumedBy: awsiam.NewWebIdentityPrincipal(cluster.OpenIdConnectProvider().OpenIdConnectProviderArn(), &map[string]interface{}{
-			"StringEquals": awscdk.NewCfnJson(stack, jsii.String("CfnJson-VPCCNIRole"), &awscdk.CfnJsonProps{
-				Value: map[string]string{
-					*cluster.OpenIdConnectProvider().OpenIdConnectProviderIssuer() + ":aud": "sts.amazonaws.com",
-					*cluster.OpenIdConnectProvider().OpenIdConnectProviderIssuer() + ":sub": "system:serviceaccount:kube-system:aws-node",
-				},
-			}),
-		}),
-		ManagedPolicies: &[]awsiam.IManagedPolicy{
-			awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AmazonEKS_CNI_Policy")),
-		},
-	})
-	awseks.NewCfnAddon(stack, jsii.String("VPCCNIAddon"), &awseks.CfnAddonProps{
-		AddonName:             jsii.String("vpc-cni"),
-		ResolveConflicts:      jsii.String("OVERWRITE"),
-		ClusterName:           cluster.ClusterName(),
-		AddonVersion:          jsii.String("v1.11.2-eksbuild.1"),
-		ServiceAccountRoleArn: cniRole.RoleArn(),
+// With pods' subnets, custom networking assigns pods' IPs from the pods' subnet of each node's AZ,
+// the secondary ENIs are in nodeSG. Nodes of the nodegroups are only launched after the ENIConfigs exist.
+func NewEksVpcCni(stack awscdk.Stack, cluster awseks.Cluster, podSubnets []awsec2.Subnet, nodeSG awsec2.ISecurityGroup,
+	nodegroups []AutoscaledNodegroup) awseks.CfnAddon {
+	// https://github.com/aws/amazon-vpc-cni-k8s#cni-configuration-variables
+	env := map[string]string{
+		"ENABLE_PREFIX_DELEGATION": "false",
+		"ENABLE_POD_ENI":           "false",
+	}
+	configurationValues := map[string]interface{}{
+		"env": env,
+	}
+	if config.PrefixDelegation(stack) {
+		env["ENABLE_PREFIX_DELEGATION"] = "true"
+		env["WARM_PREFIX_TARGET"] = "1"
+	}
+	// IPv6 pods get IPs from /80 prefixes, AmazonEKS_CNI_Policy only allows IPv4 addresses.
+	managedPolicies := []awsiam.IManagedPolicy{
+		awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AmazonEKS_CNI_Policy")),
+	}
+	var ipv6Policy awsiam.PolicyDocument = nil
+	if config.Ipv6(stack) {
+		env["ENABLE_IPv6"] = "true"
+		env["ENABLE_PREFIX_DELEGATION"] = "true"
+		managedPolicies = nil
+		ipv6Policy = awsiam.NewPolicyDocument(&awsiam.PolicyDocumentProps{
+			Statements: &[]awsiam.PolicyStatement{
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Effect: awsiam.Effect_ALLOW,
+					Actions: &[]*string{
+						jsii.String("ec2:AssignIpv6Addresses"),
+						jsii.String("ec2:DescribeInstances"),
+						jsii.String("ec2:DescribeTags"),
+						jsii.String("ec2:DescribeNetworkInterfaces"),
+						jsii.String("ec2:DescribeInstanceTypes"),
+					},
+					Resources: &[]*string{
+						jsii.String("*"),
+					},
+				}),
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Effect: awsiam.Effect_ALLOW,
+					Actions: &[]*string{
+						jsii.String("ec2:CreateTags"),
+					},
+					Resources: &[]*string{
+						stack.FormatArn(&awscdk.ArnComponents{
+							Service:      jsii.String("ec2"),
+							Region:       jsii.String("*"),
+							Account:      jsii.String("*"),
+							Resource:     jsii.String("network-interface"),
+							ResourceName: jsii.String("*"),
+						}),
+					},
+				}),
+			},
+		})
+	}
+	if len(podSubnets) > 0 {
+		env["AWS_VPC_K8S_CNI_CUSTOM_NETWORK_CFG"] = "true"
+		// ENIConfigs are named after AZs.
+		env["ENI_CONFIG_LABEL_DEF"] = "topology.kubernetes.io/zone"
+	}
+	// Security groups for pods, branch ENIs are attached by VPC resource controller of the cluster role.
+	if config.PodSecurityGroups(stack).Enabled {
+		env["ENABLE_POD_ENI"] = "true"
+		// Kubelet probes of pods with security groups reach their branch ENIs.
+		configurationValues["init"] = map[string]interface{}{
+			"env": map[string]string{
+				"DISABLE_TCP_EARLY_DEMUX": "true",
+			},
+		}
+		if clusterRole, ok := cluster.Role().(awsiam.Role); ok {
+			clusterRole.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AmazonEKSVPCResourceController")))
+		}
+	}
+	// Enforce NetworkPolicies of tenants' namespaces, VPC CNI supports it since v1.14.
+	if len(config.Tenants(stack)) > 0 && config.KubernetesMinorVersion(stack) >= 25 {
+		pinnedMinorVersion := config.AddonMinorVersion(stack, "vpc-cni")
+		if pinnedMinorVersion > 0 && pinnedMinorVersion < 14 {
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"vpc-cni add-on version %s cannot enforce NetworkPolicies of tenants, pin v1.14 or later or unpin it.",
+				config.AddonVersion(stack, "vpc-cni"))))
+		} else {
+			configurationValues["enableNetworkPolicy"] = "true"
+		}
+	}
+
+	vpcCni := newManagedAddon(stack, cluster, &managedAddonProps{
+		Id:                  "VPCCNI",
+		AddonName:           "vpc-cni",
+		RoleName:            "AmazonEKSVPCCNIRole",
+		Namespace:           "kube-system",
+		ServiceAccountName:  "aws-node",
+		ManagedPolicies:     managedPolicies,
+		PolicyName:          "AmazonEKS_CNI_IPv6_Policy",
+		PolicyDocument:      ipv6Policy,
+		ConfigurationValues: configurationValues,
 	})
+
+	for index, podSubnet := range podSubnets {
+		eniConfig := cluster.AddManifest(jsii.String("ENIConfig"+strconv.Itoa(index+1)), &map[string]interface{}{
+			"apiVersion": "crd.k8s.amazonaws.com/v1alpha1",
+			"kind":       "ENIConfig",
+			"metadata": map[string]interface{}{
+				"name": podSubnet.AvailabilityZone(),
+			},
+			"spec": map[string]interface{}{
+				"subnet": podSubnet.SubnetId(),
+				"securityGroups": []*string{
+					nodeSG.SecurityGroupId(),
+				},
+			},
+		})
+		eniConfig.Node().AddDependency(vpcCni)
+		// Nodes launched before their ENIConfig assign pods' IPs from the nodes' subnets.
+		for _, nodegroup := range nodegroups {
+			if ng := cluster.Node().TryFindChild(jsii.String("Nodegroup" + nodegroup.Name)); ng != nil {
+				ng.Node().AddDependency(eniConfig)
+			}
+		}
+	}
+
+	return vpcCni
 }
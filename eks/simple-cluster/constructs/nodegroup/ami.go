@@ -0,0 +1,57 @@
+package nodegroup
+
+import (
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+)
+
+// Nodegroup AMI type of the AMI family and target architecture.
+func AmiType(amiFamily config.AmiFamilyType, targetArch config.TargetArchType) awseks.NodegroupAmiType {
+	arm := targetArch == config.TargetArch_arm
+
+	switch amiFamily {
+	case config.AmiFamily_AL2023:
+		if arm {
+			return awseks.NodegroupAmiType_AL2023_ARM_64_STANDARD
+		}
+		return awseks.NodegroupAmiType_AL2023_X86_64_STANDARD
+	case config.AmiFamily_BOTTLEROCKET:
+		if arm {
+			return awseks.NodegroupAmiType_BOTTLEROCKET_ARM_64
+		}
+		return awseks.NodegroupAmiType_BOTTLEROCKET_X86_64
+	default:
+		if arm {
+			return awseks.NodegroupAmiType_AL2_ARM_64
+		}
+		return awseks.NodegroupAmiType_AL2_X86_64
+	}
+}
+
+// EBS volume of nodes.
+type Volume struct {
+	DeviceName string
+	Size       float64
+	// Data volume stores container images and pod data, it's the only one provisioned with custom IOPS and throughput.
+	Data bool
+}
+
+// Bottlerocket OS volume size in GiB, it must not be smaller than the AMI's snapshot.
+const bottlerocketOsVolumeSize = 4
+
+// EBS volumes of nodes of the AMI family.
+// Bottlerocket boots from a small OS volume /dev/xvda and keeps data on /dev/xvdb,
+// Amazon Linux keeps everything on the root volume /dev/xvda.
+func Volumes(amiFamily config.AmiFamilyType, dataVolumeSize float64) []Volume {
+	if amiFamily == config.AmiFamily_BOTTLEROCKET {
+		return []Volume{
+			{DeviceName: "/dev/xvda", Size: bottlerocketOsVolumeSize},
+			{DeviceName: "/dev/xvdb", Size: dataVolumeSize, Data: true},
+		}
+	}
+
+	return []Volume{
+		{DeviceName: "/dev/xvda", Size: dataVolumeSize, Data: true},
+	}
+}
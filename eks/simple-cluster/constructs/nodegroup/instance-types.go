@@ -0,0 +1,73 @@
+package nodegroup
+
+import (
+	"fmt"
+
+	"simple-cluster/config"
+)
+
+type instanceSpec struct {
+	vcpus     int
+	memoryGiB int
+	arch      config.TargetArchType
+	// Maximum network interfaces and IPv4 addresses per interface, they limit pods per node.
+	maxEnis    int
+	ipv4PerEni int
+}
+
+// vCPUs, memory, architecture and ENI limits of instance types, add the instance types used by nodegroups here.
+// Check them by:
+// aws ec2 describe-instance-types --instance-types <type> --query 'InstanceTypes[].[VCpuInfo.DefaultVCpus,MemoryInfo.SizeInMiB,ProcessorInfo.SupportedArchitectures,NetworkInfo.MaximumNetworkInterfaces,NetworkInfo.Ipv4AddressesPerInterface]'
+var instanceSpecs = map[string]instanceSpec{
+	"t3.medium":  {2, 4, config.TargetArch_x86, 3, 6},
+	"t3.large":   {2, 8, config.TargetArch_x86, 3, 12},
+	"t4g.medium": {2, 4, config.TargetArch_arm, 3, 6},
+	"t4g.large":  {2, 8, config.TargetArch_arm, 3, 12},
+	"c5.large":   {2, 4, config.TargetArch_x86, 3, 10},
+	"c5.xlarge":  {4, 8, config.TargetArch_x86, 4, 15},
+	"c5.2xlarge": {8, 16, config.TargetArch_x86, 4, 15},
+	"c5a.large":  {2, 4, config.TargetArch_x86, 3, 10},
+	"c5d.large":  {2, 4, config.TargetArch_x86, 3, 10},
+	"c6i.large":  {2, 4, config.TargetArch_x86, 3, 10},
+	"c6i.xlarge": {4, 8, config.TargetArch_x86, 4, 15},
+	"c6a.large":  {2, 4, config.TargetArch_x86, 3, 10},
+	"c6g.large":  {2, 4, config.TargetArch_arm, 3, 10},
+	"c6g.xlarge": {4, 8, config.TargetArch_arm, 4, 15},
+	"c7g.large":  {2, 4, config.TargetArch_arm, 3, 10},
+	"m5.large":   {2, 8, config.TargetArch_x86, 3, 10},
+	"m5.xlarge":  {4, 16, config.TargetArch_x86, 4, 15},
+	"m5.2xlarge": {8, 32, config.TargetArch_x86, 4, 15},
+	"m6i.large":  {2, 8, config.TargetArch_x86, 3, 10},
+	"m6i.xlarge": {4, 16, config.TargetArch_x86, 4, 15},
+	"m6g.large":  {2, 8, config.TargetArch_arm, 3, 10},
+	"m6g.xlarge": {4, 16, config.TargetArch_arm, 4, 15},
+	"m6gd.large": {2, 8, config.TargetArch_arm, 3, 10},
+	"m7g.large":  {2, 8, config.TargetArch_arm, 3, 10},
+	"m7gd.large": {2, 8, config.TargetArch_arm, 3, 10},
+	"r5.large":   {2, 16, config.TargetArch_x86, 3, 10},
+	"r6g.large":  {2, 16, config.TargetArch_arm, 3, 10},
+}
+
+// Check the instance types of a nodegroup are of the target architecture and have the same vCPUs and memory.
+func CheckInstanceTypes(instanceTypes []string, arch config.TargetArchType) error {
+	if len(instanceTypes) == 0 {
+		return fmt.Errorf("no instance types are set for architecture %s", arch)
+	}
+
+	first, ok := instanceSpecs[instanceTypes[0]]
+	for _, instanceType := range instanceTypes {
+		spec, found := instanceSpecs[instanceType]
+		if !found || !ok {
+			return fmt.Errorf("vCPUs and memory of instance type %s are unknown, add them to nodegroup/instance-types.go->instanceSpecs", instanceType)
+		}
+		if spec.arch != arch {
+			return fmt.Errorf("instance type %s is not of architecture %s", instanceType, arch)
+		}
+		if spec.vcpus != first.vcpus || spec.memoryGiB != first.memoryGiB {
+			return fmt.Errorf("instance type %s has %d vCPUs and %d GiB memory, but %s has %d vCPUs and %d GiB memory",
+				instanceType, spec.vcpus, spec.memoryGiB, instanceTypes[0], first.vcpus, first.memoryGiB)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package nodegroup
+
+import (
+	"fmt"
+)
+
+// Pods per node recommended by EKS with prefix delegation, the instance types here have less than 30 vCPUs.
+const maxPodsWithPrefixDelegation = 110
+
+// Maximum number of pods a node can run with VPC CNI, the same as the EKS optimized AMIs calculate:
+// ENIs * (IPv4 addresses per ENI - 1) + 2. The primary IP of each ENI is not for pods,
+// and 2 pods (aws-node and kube-proxy) use host network.
+// With custom networking, pods don't use the primary ENI. With prefix delegation, each IP is a /28 prefix of 16 IPs.
+// A nodegroup of mixed instance types uses the smallest one, so that pods fit on every node.
+func MaxPods(instanceTypes []string, customNetworking bool, prefixDelegation bool) (int, error) {
+	maxPods := 0
+	for _, instanceType := range instanceTypes {
+		spec, ok := instanceSpecs[instanceType]
+		if !ok {
+			return 0, fmt.Errorf("ENI limits of instance type %s are unknown, add them to nodegroup/instance-types.go->instanceSpecs", instanceType)
+		}
+
+		enis, ipsPerEni := spec.maxEnis, spec.ipv4PerEni-1
+		if customNetworking {
+			enis--
+		}
+		if prefixDelegation {
+			ipsPerEni *= 16
+		}
+		pods := enis*ipsPerEni + 2
+		if prefixDelegation && pods > maxPodsWithPrefixDelegation {
+			pods = maxPodsWithPrefixDelegation
+		}
+		if maxPods == 0 || pods < maxPods {
+			maxPods = pods
+		}
+	}
+
+	return maxPods, nil
+}
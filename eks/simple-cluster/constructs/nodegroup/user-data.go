@@ -0,0 +1,190 @@
+package nodegroup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseks"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Launch template user data properties
+type UserDataProps struct {
+	Cluster   awseks.Cluster
+	AmiFamily config.AmiFamilyType
+	// Nodes of a custom AMI are not bootstrapped by EKS, the user data must do it.
+	CustomAmi        bool
+	MaxPods          int
+	KubeletExtraArgs []string
+	// Node labels of a custom AMI, EKS applies them for EKS optimized AMIs.
+	NodeLabels map[string]string
+	// Node taints of a custom AMI as key to value:effect, EKS applies the nodegroup's taints for EKS optimized AMIs.
+	NodeTaints map[string]string
+}
+
+const mimeBoundary = "==BOUNDARY=="
+
+// Drop-in of kubelet.service on AL2 nodes of EKS optimized AMIs, it sorts after bootstrap.sh's drop-ins.
+const al2KubeletDropIn = "/etc/systemd/system/kubelet.service.d/40-kubelet-user-args.conf"
+
+// Generate launch template user data of the AMI family.
+// Managed nodegroups merge it with the user data of EKS optimized AMIs,
+// so AL2 and AL2023 user data must be MIME multi-part and Bottlerocket user data must be TOML.
+func NewUserData(props *UserDataProps) *string {
+	switch props.AmiFamily {
+	case config.AmiFamily_AL2023:
+		return jsii.String(al2023UserData(props))
+	case config.AmiFamily_BOTTLEROCKET:
+		return jsii.String(bottlerocketUserData(props))
+	default:
+		return jsii.String(al2UserData(props))
+	}
+}
+
+// AL2 nodes are bootstrapped by /etc/eks/bootstrap.sh.
+// For EKS optimized AMIs, EKS runs bootstrap.sh after the user data, so kubelet args can't be passed to it.
+func al2UserData(props *UserDataProps) string {
+	kubeletArgs := append([]string{fmt.Sprintf("--max-pods=%d", props.MaxPods)}, props.KubeletExtraArgs...)
+
+	if props.CustomAmi {
+		if len(props.NodeLabels) > 0 {
+			kubeletArgs = append(kubeletArgs, "--node-labels="+nodeLabels(props.NodeLabels))
+		}
+		if len(props.NodeTaints) > 0 {
+			kubeletArgs = append(kubeletArgs, "--register-with-taints="+nodeLabels(props.NodeTaints))
+		}
+
+		return strings.Join([]string{
+			"#!/bin/bash",
+			"set -ex",
+			fmt.Sprintf("/etc/eks/bootstrap.sh %s --apiserver-endpoint %s --b64-cluster-ca %s --dns-cluster-ip %s --use-max-pods false --kubelet-extra-args '%s'",
+				*props.Cluster.ClusterName(), *props.Cluster.ClusterEndpoint(), *props.Cluster.ClusterCertificateAuthorityData(),
+				config.ClusterDnsIp, strings.Join(kubeletArgs, " ")),
+		}, "\n")
+	}
+
+	// EKS passes its own --kubelet-extra-args to bootstrap.sh, so the args are added by a kubelet drop-in instead.
+	// The drop-in appends them to kubelet.service's documented $KUBELET_ARGS $KUBELET_EXTRA_ARGS command line,
+	// later flags win, and bootstrap.sh reloads systemd before it starts kubelet.
+	return mimeMultiPart("text/x-shellscript; charset=\"us-ascii\"", strings.Join([]string{
+		"#!/bin/bash",
+		"set -ex",
+		"mkdir -p /etc/systemd/system/kubelet.service.d",
+		"cat <<'EOF' > " + al2KubeletDropIn,
+		"[Service]",
+		fmt.Sprintf("Environment='KUBELET_USER_ARGS=%s'", strings.Join(kubeletArgs, " ")),
+		"ExecStart=",
+		"ExecStart=/usr/bin/kubelet $KUBELET_ARGS $KUBELET_EXTRA_ARGS $KUBELET_USER_ARGS",
+		"EOF",
+	}, "\n"))
+}
+
+// AL2023 nodes are bootstrapped by nodeadm, which merges all NodeConfig documents in the user data.
+func al2023UserData(props *UserDataProps) string {
+	lines := []string{
+		"---",
+		"apiVersion: node.eks.aws/v1alpha1",
+		"kind: NodeConfig",
+		"spec:",
+	}
+
+	flags := append([]string{}, props.KubeletExtraArgs...)
+	if props.CustomAmi {
+		lines = append(lines,
+			"  cluster:",
+			fmt.Sprintf("    name: %s", *props.Cluster.ClusterName()),
+			fmt.Sprintf("    apiServerEndpoint: %s", *props.Cluster.ClusterEndpoint()),
+			fmt.Sprintf("    certificateAuthority: %s", *props.Cluster.ClusterCertificateAuthorityData()),
+			fmt.Sprintf("    cidr: %s", config.ServiceIpv4Cidr),
+		)
+		if len(props.NodeLabels) > 0 {
+			flags = append(flags, "--node-labels="+nodeLabels(props.NodeLabels))
+		}
+		if len(props.NodeTaints) > 0 {
+			flags = append(flags, "--register-with-taints="+nodeLabels(props.NodeTaints))
+		}
+	}
+
+	lines = append(lines,
+		"  kubelet:",
+		"    config:",
+		fmt.Sprintf("      maxPods: %d", props.MaxPods),
+	)
+	if len(flags) > 0 {
+		lines = append(lines, "    flags:")
+		for _, flag := range flags {
+			lines = append(lines, fmt.Sprintf("      - %q", flag))
+		}
+	}
+
+	return mimeMultiPart("application/node.eks.aws", strings.Join(lines, "\n"))
+}
+
+// Bottlerocket is configured by TOML settings, it doesn't run scripts or accept kubelet flags.
+func bottlerocketUserData(props *UserDataProps) string {
+	lines := []string{
+		"[settings.kubernetes]",
+		fmt.Sprintf("max-pods = %d", props.MaxPods),
+	}
+
+	if props.CustomAmi {
+		lines = append(lines,
+			fmt.Sprintf("cluster-name = %q", *props.Cluster.ClusterName()),
+			fmt.Sprintf("api-server = %q", *props.Cluster.ClusterEndpoint()),
+			fmt.Sprintf("cluster-certificate = %q", *props.Cluster.ClusterCertificateAuthorityData()),
+			fmt.Sprintf("cluster-dns-ip = %q", config.ClusterDnsIp),
+		)
+		if len(props.NodeLabels) > 0 {
+			lines = append(lines, "", "[settings.kubernetes.node-labels]")
+			for _, key := range sortedKeys(props.NodeLabels) {
+				lines = append(lines, fmt.Sprintf("%q = %q", key, props.NodeLabels[key]))
+			}
+		}
+		if len(props.NodeTaints) > 0 {
+			// Bottlerocket takes a list of value:effect per taint key.
+			lines = append(lines, "", "[settings.kubernetes.node-taints]")
+			for _, key := range sortedKeys(props.NodeTaints) {
+				lines = append(lines, fmt.Sprintf("%q = [%q]", key, props.NodeTaints[key]))
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func mimeMultiPart(contentType string, content string) string {
+	return strings.Join([]string{
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"", mimeBoundary),
+		"",
+		"--" + mimeBoundary,
+		"Content-Type: " + contentType,
+		"",
+		content,
+		"",
+		"--" + mimeBoundary + "--",
+		"",
+	}, "\n")
+}
+
+func nodeLabels(labels map[string]string) string {
+	var pairs []string
+	for _, key := range sortedKeys(labels) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
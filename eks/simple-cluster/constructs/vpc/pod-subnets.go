@@ -0,0 +1,56 @@
+package vpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Associate the secondary CIDR of 'cdk.json/context/podCidr' with the VPC, and split it into a pods' subnet per AZ.
+// Pods' traffic leaving the VPC is SNATed to the nodes' primary IPs by VPC CNI,
+// so the subnets only need the local route, in the AZs of the nodes' subnets.
+func newPodSubnets(stack awscdk.Stack, vpc awsec2.Vpc, nodeSubnets []awsec2.ISubnet) []awsec2.Subnet {
+	podCidr := config.PodCidr(stack)
+	_, ipNet, err := net.ParseCIDR(podCidr)
+	mask := 0
+	if err == nil {
+		mask, _ = ipNet.Mask.Size()
+	}
+	// 2 more bits split the CIDR into 4 subnets, 1 of them is spare.
+	if err != nil || ipNet.IP.To4() == nil || mask < 16 || mask > 24 {
+		awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+			"Pod CIDR %s is not valid, set podCidr to an IPv4 CIDR from /16 to /24, e.g. 100.64.0.0/16.", podCidr)))
+		return nil
+	}
+
+	cidrBlock := awsec2.NewCfnVPCCidrBlock(stack, jsii.String("PodCidrBlock"), &awsec2.CfnVPCCidrBlockProps{
+		VpcId:     vpc.VpcId(),
+		CidrBlock: jsii.String(ipNet.String()),
+	})
+
+	var podSubnets []awsec2.Subnet
+	subnetMask := mask + 2
+	for index, nodeSubnet := range nodeSubnets {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(ipNet.IP.To4())+uint32(index)<<(32-subnetMask))
+
+		podSubnet := awsec2.NewSubnet(stack, jsii.String("PodSubnet0"+strconv.Itoa(index+1)), &awsec2.SubnetProps{
+			VpcId:               vpc.VpcId(),
+			AvailabilityZone:    nodeSubnet.AvailabilityZone(),
+			CidrBlock:           jsii.String(ip.String() + "/" + strconv.Itoa(subnetMask)),
+			MapPublicIpOnLaunch: jsii.Bool(false),
+		})
+		podSubnet.Node().AddDependency(cidrBlock)
+		awscdk.Tags_Of(podSubnet).Add(jsii.String("Name"), jsii.String(*stack.StackName()+"/PodSubnet0"+strconv.Itoa(index+1)), &awscdk.TagProps{})
+		podSubnets = append(podSubnets, podSubnet)
+	}
+
+	return podSubnets
+}
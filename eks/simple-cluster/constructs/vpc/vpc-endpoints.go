@@ -0,0 +1,37 @@
+package vpc
+
+import (
+	"simple-cluster/config"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+
+	"vpc-endpoints"
+)
+
+// Create VPC endpoints for isolated subnets, nodes can still join the cluster, pull images from ECR,
+// get credentials of IRSA and Pod Identity, assign pods' IPs and ship logs without NAT.
+// Controllers still reach their APIs, AWS Load Balancer Controller by ELB, Cluster Autoscaler by Auto Scaling and EKS.
+// Pods of custom networking and IPv6 pods call the endpoints from outside the VPC's primary CIDR.
+func newVpcEndpoints(stack awscdk.Stack, vpc awsec2.Vpc) {
+	peers := []awsec2.IPeer{
+		awsec2.Peer_Ipv4(jsii.String(config.VpcCidr)),
+	}
+	if podCidr := config.PodCidr(stack); len(podCidr) > 0 {
+		peers = append(peers, awsec2.Peer_Ipv4(jsii.String(podCidr)))
+	}
+	if config.Ipv6(stack) {
+		peers = append(peers, awsec2.Peer_Ipv6(awscdk.Fn_Select(jsii.Number(0), vpc.VpcIpv6CidrBlocks())))
+	}
+
+	endpoints.NewVpcEndpoints(vpc, &endpoints.VpcEndpointsProps{
+		Peers: peers,
+		ExtraInterfaceEndpoints: []endpoints.InterfaceEndpoint{
+			{Id: "EksEndpoint", Service: awsec2.InterfaceVpcEndpointAwsService_EKS()},
+			{Id: "EksAuthEndpoint", Service: awsec2.InterfaceVpcEndpointAwsService_EKS_AUTH()},
+			{Id: "ElbEndpoint", Service: awsec2.InterfaceVpcEndpointAwsService_ELASTIC_LOAD_BALANCING()},
+			{Id: "AutoScalingEndpoint", Service: awsec2.InterfaceVpcEndpointAwsService_AUTOSCALING()},
+		},
+	})
+}
@@ -1,6 +1,7 @@
 package vpc
 
 import (
+	"fmt"
 	"simple-cluster/config"
 	"strconv"
 
@@ -9,23 +10,56 @@ import (
 	"github.com/aws/jsii-runtime-go"
 )
 
-func NewEksVpc(stack awscdk.Stack) awsec2.Vpc {
+// Create VPC of EKS cluster, it returns pods' subnets too if 'cdk.json/context/podCidr' is set.
+func NewEksVpc(stack awscdk.Stack) (awsec2.Vpc, []awsec2.Subnet) {
 	ngwNum := 0
+	// Dual-stack subnets get a /64 of the VPC's IPv6 CIDR, private subnets route IPv6 to an egress-only internet gateway.
+	ipProtocol := awsec2.IpProtocol_IPV4_ONLY
+	var ipv6AssignAddressOnCreation *bool = nil
+	if config.Ipv6(stack) {
+		ipProtocol = awsec2.IpProtocol_DUAL_STACK
+		ipv6AssignAddressOnCreation = jsii.Bool(true)
+	}
 	subnetConfigs := []*awsec2.SubnetConfiguration{
 		{
-			Name:                jsii.String("PublicSubnet"),
-			MapPublicIpOnLaunch: jsii.Bool(true),
-			SubnetType:          awsec2.SubnetType_PUBLIC,
-			CidrMask:            jsii.Number(float64(config.SubnetMask)),
+			Name:                        jsii.String(config.PublicSubnetGroupName),
+			MapPublicIpOnLaunch:         jsii.Bool(true),
+			SubnetType:                  awsec2.SubnetType_PUBLIC,
+			CidrMask:                    jsii.Number(float64(config.SubnetMask)),
+			Ipv6AssignAddressOnCreation: ipv6AssignAddressOnCreation,
 		},
 	}
 
+	var natProvider awsec2.NatProvider = nil
 	if config.DeploymentStage(stack) == config.DeploymentStage_PROD {
-		ngwNum = config.MaxAzs
+		privateSubnetType := awsec2.SubnetType_PRIVATE_WITH_NAT
+		switch config.NatMode(stack) {
+		case config.NatMode_PER_AZ:
+			ngwNum = config.MaxAzs
+		case config.NatMode_SINGLE:
+			ngwNum = 1
+		case config.NatMode_NAT_INSTANCE:
+			// NAT instance is a single point of failure, it trades availability for cost.
+			ngwNum = 1
+			natProvider = awsec2.NatProvider_InstanceV2(&awsec2.NatInstanceProps{
+				InstanceType: awsec2.NewInstanceType(jsii.String(config.NatInstanceType)),
+				MachineImage: awsec2.MachineImage_LatestAmazonLinux2023(&awsec2.AmazonLinux2023ImageSsmParameterProps{
+					CpuType: awsec2.AmazonLinuxCpuType_ARM_64,
+				}),
+				DefaultAllowedTraffic: awsec2.NatTrafficDirection_OUTBOUND_ONLY,
+			})
+		case config.NatMode_NONE_ENDPOINTS:
+			privateSubnetType = awsec2.SubnetType_PRIVATE_ISOLATED
+		default:
+			awscdk.Annotations_Of(stack).AddError(jsii.String(fmt.Sprintf(
+				"NAT mode %s is not valid, allowed values are: per-az, single, nat-instance, none+endpoints.", config.NatMode(stack))))
+			ngwNum = config.MaxAzs
+		}
 		privateSub := &awsec2.SubnetConfiguration{
-			Name:       jsii.String("PrivateSubnet"),
-			SubnetType: awsec2.SubnetType_PRIVATE_WITH_NAT,
-			CidrMask:   jsii.Number(float64(config.SubnetMask)),
+			Name:                        jsii.String(config.PrivateSubnetGroupName),
+			SubnetType:                  privateSubnetType,
+			CidrMask:                    jsii.Number(float64(config.SubnetMask)),
+			Ipv6AssignAddressOnCreation: ipv6AssignAddressOnCreation,
 		}
 		subnetConfigs = append(subnetConfigs, privateSub)
 	}
@@ -37,7 +71,9 @@ func NewEksVpc(stack awscdk.Stack) awsec2.Vpc {
 		EnableDnsSupport:    jsii.Bool(true),
 		MaxAzs:              jsii.Number(float64(config.MaxAzs)),
 		NatGateways:         jsii.Number(float64(ngwNum)),
+		NatGatewayProvider:  natProvider,
 		SubnetConfiguration: &subnetConfigs,
+		IpProtocol:          ipProtocol,
 	})
 
 	// Tagging subnets
@@ -47,8 +83,19 @@ func NewEksVpc(stack awscdk.Stack) awsec2.Vpc {
 		awscdk.Tags_Of(subnet).Add(jsii.String("Name"), jsii.String(subnetName), &awscdk.TagProps{})
 		awscdk.Tags_Of(subnet).Add(jsii.String("kubernetes.io/role/elb"), jsii.String("1"), &awscdk.TagProps{})
 	}
-	// Private subnets
-	for index, subnet := range *vpc.PrivateSubnets() {
+	if natInstance, ok := natProvider.(awsec2.NatInstanceProviderV2); ok {
+		natInstance.Connections().AllowFrom(awsec2.Peer_Ipv4(jsii.String(config.VpcCidr)), awsec2.Port_AllTraffic(),
+			jsii.String("Allow private subnets to reach the internet by NAT instance."))
+	}
+	// Private subnets have no NAT in none+endpoints mode, they're isolated subnets then.
+	privateSubnets := vpc.PrivateSubnets()
+	if len(*privateSubnets) == 0 {
+		privateSubnets = vpc.IsolatedSubnets()
+	}
+	if len(*vpc.IsolatedSubnets()) > 0 {
+		newVpcEndpoints(stack, vpc)
+	}
+	for index, subnet := range *privateSubnets {
 		subnetName := *stack.StackName() + "/PrivateSubnet0" + strconv.Itoa(index+1)
 		awscdk.Tags_Of(subnet).Add(jsii.String("Name"), jsii.String(subnetName), &awscdk.TagProps{})
 		awscdk.Tags_Of(subnet).Add(jsii.String("kubernetes.io/role/internal-elb"), jsii.String("1"), &awscdk.TagProps{})
@@ -58,5 +105,15 @@ func NewEksVpc(stack awscdk.Stack) awsec2.Vpc {
 		Value: vpc.VpcId(),
 	})
 
-	return vpc
+	// Nodes are in private subnets in PROD stage, and public subnets in DEV stage.
+	var podSubnets []awsec2.Subnet
+	if len(config.PodCidr(stack)) > 0 {
+		nodeSubnets := vpc.PublicSubnets()
+		if config.DeploymentStage(stack) == config.DeploymentStage_PROD {
+			nodeSubnets = privateSubnets
+		}
+		podSubnets = newPodSubnets(stack, vpc, *nodeSubnets)
+	}
+
+	return vpc, podSubnets
 }
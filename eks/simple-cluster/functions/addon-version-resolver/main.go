@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+)
+
+// EKSDescribeAddonVersionsAPI defines the interface for the DescribeAddonVersions function.
+// We use this interface to test the function using a mocked service.
+type EKSDescribeAddonVersionsAPI interface {
+	DescribeAddonVersions(ctx context.Context,
+		params *eks.DescribeAddonVersionsInput,
+		optFns ...func(*eks.Options)) (*eks.DescribeAddonVersionsOutput, error)
+}
+
+type CustomResEvent struct {
+	RequestType           string
+	ServiceToken          string
+	ResponseURL           string
+	LogicalResourceId     string
+	PhysicalResourceId    string
+	ResourceType          string
+	RequestId             string
+	StackId               string
+	ResourceProperties    map[string]interface{}
+	OldResourceProperties map[string]interface{}
+}
+
+type CustomResResponse struct {
+	PhysicalResourceId string
+	Data               map[string]interface{}
+	NoEcho             bool
+}
+
+// LatestAddonVersion returns the latest version of the add-on (e.g. vpc-cni) that
+// is compatible with the given Kubernetes version (e.g. 1.21).
+// If no compatible version exists, an empty string and an error are returned.
+func LatestAddonVersion(c context.Context, api EKSDescribeAddonVersionsAPI, addonName string, kubernetesVersion string) (string, error) {
+	latest := ""
+
+	input := &eks.DescribeAddonVersionsInput{
+		AddonName:         aws.String(addonName),
+		KubernetesVersion: aws.String(kubernetesVersion),
+	}
+	paginator := eks.NewDescribeAddonVersionsPaginator(api, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(c)
+		if err != nil {
+			return "", err
+		}
+
+		for _, addon := range output.Addons {
+			for _, addonVersion := range addon.AddonVersions {
+				compatible := false
+				for _, compatibility := range addonVersion.Compatibilities {
+					if aws.ToString(compatibility.ClusterVersion) == kubernetesVersion {
+						compatible = true
+						break
+					}
+				}
+				if compatible && compareAddonVersions(aws.ToString(addonVersion.AddonVersion), latest) > 0 {
+					latest = aws.ToString(addonVersion.AddonVersion)
+				}
+			}
+		}
+	}
+
+	if len(latest) == 0 {
+		return "", fmt.Errorf("no %s add-on version is compatible with Kubernetes %s", addonName, kubernetesVersion)
+	}
+
+	return latest, nil
+}
+
+// compareAddonVersions compares two add-on versions in the format of v1.11.2-eksbuild.1.
+// It returns 1 if a > b, -1 if a < b, 0 otherwise. An empty version is lower than any other version.
+func compareAddonVersions(a string, b string) int {
+	av := parseAddonVersion(a)
+	bv := parseAddonVersion(b)
+
+	for i := 0; i < len(av) || i < len(bv); i++ {
+		var x, y int
+		if i < len(av) {
+			x = av[i]
+		}
+		if i < len(bv) {
+			y = bv[i]
+		}
+		if x > y {
+			return 1
+		}
+		if x < y {
+			return -1
+		}
+	}
+
+	return 0
+}
+
+// parseAddonVersion turns v1.11.2-eksbuild.1 into [1, 11, 2, 1].
+func parseAddonVersion(version string) []int {
+	var numbers []int
+
+	fields := strings.FieldsFunc(version, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	for _, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
+
+func HandleRequest(ctx context.Context, event CustomResEvent) (CustomResResponse, error) {
+	var response CustomResResponse
+
+	// Extract input parameters.
+	addonName := fmt.Sprintf("%v", event.ResourceProperties["AddonName"])
+	kubernetesVersion := fmt.Sprintf("%v", event.ResourceProperties["KubernetesVersion"])
+	physicalResId := addonName + "-" + kubernetesVersion
+
+	// Nothing to clean up, the add-on itself is managed by AWS::EKS::Addon.
+	if event.RequestType == "Delete" {
+		fmt.Printf("OnDelete, PhysicalResourceId: %s\n", event.PhysicalResourceId)
+		response = CustomResResponse{
+			PhysicalResourceId: event.PhysicalResourceId,
+		}
+		return response, nil
+	}
+
+	// Load AWS configuration.
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return response, err
+	}
+
+	// Create EKS client.
+	client := eks.NewFromConfig(cfg)
+
+	fmt.Printf("On%s, AddonName: %s, KubernetesVersion: %s\n", event.RequestType, addonName, kubernetesVersion)
+	addonVersion, err := LatestAddonVersion(ctx, client, addonName, kubernetesVersion)
+	if err != nil {
+		fmt.Println(err.Error())
+		return response, err
+	}
+	fmt.Printf("Resolved %s add-on version: %s\n", addonName, addonVersion)
+
+	response = CustomResResponse{
+		PhysicalResourceId: physicalResId,
+		Data: map[string]interface{}{
+			"AddonVersion": addonVersion,
+		},
+		NoEcho: false,
+	}
+
+	return response, nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks/types"
+)
+
+// Mocked EKSDescribeAddonVersionsAPI returning a page per call, pages are chained by NextToken.
+type mockDescribeAddonVersionsAPI struct {
+	pages []*eks.DescribeAddonVersionsOutput
+	err   error
+}
+
+func (m *mockDescribeAddonVersionsAPI) DescribeAddonVersions(ctx context.Context,
+	params *eks.DescribeAddonVersionsInput,
+	optFns ...func(*eks.Options)) (*eks.DescribeAddonVersionsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page = len(aws.ToString(params.NextToken))
+	}
+	output := *m.pages[page]
+	if page+1 < len(m.pages) {
+		output.NextToken = aws.String(strings.Repeat("n", page+1))
+	}
+
+	return &output, nil
+}
+
+func addonVersions(versions map[string][]string) *eks.DescribeAddonVersionsOutput {
+	addon := types.AddonInfo{AddonName: aws.String("vpc-cni")}
+	for version, clusterVersions := range versions {
+		info := types.AddonVersionInfo{AddonVersion: aws.String(version)}
+		for _, clusterVersion := range clusterVersions {
+			info.Compatibilities = append(info.Compatibilities, types.Compatibility{ClusterVersion: aws.String(clusterVersion)})
+		}
+		addon.AddonVersions = append(addon.AddonVersions, info)
+	}
+
+	return &eks.DescribeAddonVersionsOutput{Addons: []types.AddonInfo{addon}}
+}
+
+func TestLatestAddonVersion(t *testing.T) {
+	tests := []struct {
+		name              string
+		api               *mockDescribeAddonVersionsAPI
+		kubernetesVersion string
+		want              string
+		wantErr           string
+	}{
+		{
+			name: "latest compatible version",
+			api: &mockDescribeAddonVersionsAPI{pages: []*eks.DescribeAddonVersionsOutput{
+				addonVersions(map[string][]string{
+					"v1.11.2-eksbuild.1": {"1.21", "1.22"},
+					"v1.11.4-eksbuild.1": {"1.22", "1.23"},
+					"v1.12.0-eksbuild.1": {"1.23", "1.24"},
+				}),
+			}},
+			kubernetesVersion: "1.22",
+			want:              "v1.11.4-eksbuild.1",
+		},
+		{
+			name: "versions of all pages",
+			api: &mockDescribeAddonVersionsAPI{pages: []*eks.DescribeAddonVersionsOutput{
+				addonVersions(map[string][]string{"v1.18.1-eksbuild.1": {"1.30"}}),
+				addonVersions(map[string][]string{"v1.18.1-eksbuild.3": {"1.30"}}),
+				addonVersions(map[string][]string{"v1.9.3-eksbuild.1": {"1.30"}}),
+			}},
+			kubernetesVersion: "1.30",
+			want:              "v1.18.1-eksbuild.3",
+		},
+		{
+			name: "no compatible version",
+			api: &mockDescribeAddonVersionsAPI{pages: []*eks.DescribeAddonVersionsOutput{
+				addonVersions(map[string][]string{"v1.11.2-eksbuild.1": {"1.21"}}),
+			}},
+			kubernetesVersion: "1.30",
+			wantErr:           "no vpc-cni add-on version is compatible with Kubernetes 1.30",
+		},
+		{
+			name:              "API error",
+			api:               &mockDescribeAddonVersionsAPI{err: errors.New("AccessDeniedException")},
+			kubernetesVersion: "1.30",
+			wantErr:           "AccessDeniedException",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LatestAddonVersion(context.TODO(), tt.api, "vpc-cni", tt.kubernetesVersion)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareAddonVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.11.2-eksbuild.1", "v1.11.2-eksbuild.1", 0},
+		{"v1.12.0-eksbuild.1", "v1.11.4-eksbuild.1", 1},
+		{"v1.9.3-eksbuild.1", "v1.10.1-eksbuild.1", -1},
+		{"v1.18.1-eksbuild.3", "v1.18.1-eksbuild.1", 1},
+		{"v1.30.0-eksbuild.3", "", 1},
+		{"", "v1.8.4-eksbuild.1", -1},
+		{"", "", 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareAddonVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareAddonVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
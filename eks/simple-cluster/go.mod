@@ -1,11 +1,29 @@
 module simple-cluster
 
-go 1.17
+go 1.21
 
 require (
-	github.com/aws/aws-cdk-go/awscdk/v2 v2.8.0
-	github.com/aws/constructs-go/constructs/v10 v10.0.9
-	github.com/aws/jsii-runtime-go v1.52.1
+	github.com/aws/aws-cdk-go/awscdk/v2 v2.150.0
+	github.com/aws/constructs-go/constructs/v10 v10.3.0
+	github.com/aws/jsii-runtime-go v1.101.0
+	gopkg.in/yaml.v3 v3.0.1
+	vpc-endpoints v0.0.0
 )
 
-require github.com/Masterminds/semver/v3 v3.1.1 // indirect
+require (
+	github.com/Masterminds/semver/v3 v3.2.1 // indirect
+	github.com/cdklabs/awscdk-asset-awscli-go/awscliv1/v2 v2.2.202 // indirect
+	github.com/cdklabs/awscdk-asset-kubectl-go/kubectlv20/v2 v2.1.2 // indirect
+	github.com/cdklabs/awscdk-asset-node-proxy-agent-go/nodeproxyagentv6/v2 v2.0.3 // indirect
+	github.com/fatih/color v1.17.0 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/yuin/goldmark v1.4.13 // indirect
+	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
+)
+
+// VPC endpoints of isolated subnets are shared with vpc/single_vpc.
+replace vpc-endpoints => ../../vpc/endpoints
@@ -0,0 +1,361 @@
+// Write a kubeconfig context of the EKS cluster from the stack outputs.
+// Tokens are generated by 'aws eks get-token' for a chosen role, the stack's kubectl role by default.
+//
+// Usage:
+//
+//	go run ./tools/kubeconfig                                                   # from cdk.out/cluster-info.json
+//	go run ./tools/kubeconfig -stack <stack name> -region <region>              # from CloudFormation outputs
+//	go run ./tools/kubeconfig -role-arn <role arn> -context <name> -kubeconfig <path>
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Outputs file written by 'cdk-cli-wrapper-dev.sh'.
+const defaultOutputsFile = "cdk.out/cluster-info.json"
+
+// Cluster info read from the stack outputs.
+type ClusterInfo struct {
+	ClusterName              string
+	ApiServerEndpoint        string
+	CertificateAuthorityData string
+	KubectlRoleArn           string
+	// Region of the API server endpoint.
+	Region string
+}
+
+// Context written to the kubeconfig.
+type ContextOptions struct {
+	// Name of the context, its cluster and user, the cluster name by default.
+	Name string
+	// Role assumed by 'aws eks get-token', the stack's kubectl role by default.
+	RoleArn string
+	// AWS CLI profile of 'aws eks get-token', empty means the default credentials.
+	Profile string
+	// Region of 'aws eks get-token', the cluster's region by default.
+	Region string
+}
+
+// CloudFormationAPI defines the interface for reading the outputs of a stack.
+// We use this interface to test the functions without calling AWS CLI.
+type CloudFormationAPI interface {
+	DescribeStackOutputs(stackName string, region string) ([]byte, error)
+}
+
+// AWS CLI implementation of CloudFormationAPI.
+type awsCli struct{}
+
+func (awsCli) DescribeStackOutputs(stackName string, region string) ([]byte, error) {
+	args := []string{"cloudformation", "describe-stacks", "--stack-name", stackName, "--query", "Stacks[0].Outputs", "--output", "json"}
+	if len(region) > 0 {
+		args = append(args, "--region", region)
+	}
+
+	output, err := exec.Command("aws", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("aws cloudformation describe-stacks: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+
+	return output, err
+}
+
+// ClusterInfoFromOutputs returns the cluster info of the stack outputs, keyed by output name.
+func ClusterInfoFromOutputs(outputs map[string]string) (ClusterInfo, error) {
+	info := ClusterInfo{
+		ClusterName:              outputs["clusterName"],
+		ApiServerEndpoint:        outputs["apiServerEndpoint"],
+		CertificateAuthorityData: outputs["certificateAuthorityData"],
+		KubectlRoleArn:           outputs["kubectlRoleArn"],
+	}
+
+	var missing []string
+	for _, output := range []struct{ name, value string }{
+		{"clusterName", info.ClusterName},
+		{"apiServerEndpoint", info.ApiServerEndpoint},
+		{"certificateAuthorityData", info.CertificateAuthorityData},
+	} {
+		if len(output.value) == 0 {
+			missing = append(missing, output.name)
+		}
+	}
+	if len(missing) > 0 {
+		return ClusterInfo{}, fmt.Errorf("stack outputs %s are missing", strings.Join(missing, ", "))
+	}
+
+	info.Region = regionFromEndpoint(info.ApiServerEndpoint)
+
+	return info, nil
+}
+
+// ReadOutputsFile returns the cluster info of an outputs file of 'cdk deploy --outputs-file', which is keyed by stack name.
+// If stackName is empty, the file must have exactly one stack with the cluster outputs, other stacks like
+// the Velero replica stack are skipped.
+func ReadOutputsFile(path string, stackName string) (ClusterInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+
+	var stacks map[string]map[string]string
+	if err := json.Unmarshal(data, &stacks); err != nil {
+		return ClusterInfo{}, fmt.Errorf("%s is not an outputs file: %w", path, err)
+	}
+
+	if len(stackName) > 0 {
+		outputs, ok := stacks[stackName]
+		if !ok {
+			return ClusterInfo{}, fmt.Errorf("stack %s is not in %s", stackName, path)
+		}
+		return ClusterInfoFromOutputs(outputs)
+	}
+
+	var clusterStacks []string
+	for name, outputs := range stacks {
+		if len(outputs["apiServerEndpoint"]) > 0 {
+			clusterStacks = append(clusterStacks, name)
+		}
+	}
+	sort.Strings(clusterStacks)
+	switch len(clusterStacks) {
+	case 0:
+		return ClusterInfo{}, fmt.Errorf("no stack of %s has cluster outputs", path)
+	case 1:
+		return ClusterInfoFromOutputs(stacks[clusterStacks[0]])
+	default:
+		return ClusterInfo{}, fmt.Errorf("stacks %s of %s have cluster outputs, choose one by -stack",
+			strings.Join(clusterStacks, ", "), path)
+	}
+}
+
+// ReadStackOutputs returns the cluster info of a deployed stack's CloudFormation outputs.
+func ReadStackOutputs(api CloudFormationAPI, stackName string, region string) (ClusterInfo, error) {
+	data, err := api.DescribeStackOutputs(stackName, region)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+
+	var stackOutputs []struct {
+		OutputKey   string
+		OutputValue string
+	}
+	if err := json.Unmarshal(data, &stackOutputs); err != nil {
+		return ClusterInfo{}, fmt.Errorf("outputs of stack %s are not valid: %w", stackName, err)
+	}
+
+	outputs := map[string]string{}
+	for _, output := range stackOutputs {
+		outputs[output.OutputKey] = output.OutputValue
+	}
+
+	info, err := ClusterInfoFromOutputs(outputs)
+	if err != nil {
+		return ClusterInfo{}, fmt.Errorf("stack %s: %w", stackName, err)
+	}
+
+	return info, nil
+}
+
+// Region of an EKS API server endpoint, e.g. ap-northeast-1 of
+// https://AB123D8E12345CD123AA92855957B4F8.gr7.ap-northeast-1.eks.amazonaws.com.
+func regionFromEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+
+	labels := strings.Split(u.Hostname(), ".")
+	for i := 1; i < len(labels); i++ {
+		if labels[i] == "eks" {
+			return labels[i-1]
+		}
+	}
+
+	return ""
+}
+
+// Options of the cluster's defaults, see ContextOptions.
+func (options ContextOptions) withDefaults(info ClusterInfo) ContextOptions {
+	if len(options.Name) == 0 {
+		options.Name = info.ClusterName
+	}
+	if len(options.RoleArn) == 0 {
+		options.RoleArn = info.KubectlRoleArn
+	}
+	if len(options.Region) == 0 {
+		options.Region = info.Region
+	}
+
+	return options
+}
+
+// Name of a role ARN, e.g. DevTeam of arn:aws:iam::123456789012:role/teams/DevTeam.
+func roleName(roleArn string) string {
+	return roleArn[strings.LastIndex(roleArn, "/")+1:]
+}
+
+// MergeKubeconfig adds the cluster's context to an existing kubeconfig and returns the new kubeconfig.
+// Entries of the same name are replaced, other entries and fields are kept.
+// The existing kubeconfig can be empty, e.g. the file doesn't exist yet.
+func MergeKubeconfig(existing []byte, info ClusterInfo, options ContextOptions, setCurrent bool) ([]byte, error) {
+	options = options.withDefaults(info)
+	if len(options.Region) == 0 {
+		return nil, fmt.Errorf("region of %s is unknown, set it by -region", info.ApiServerEndpoint)
+	}
+
+	kubeconfig := map[string]interface{}{}
+	if err := yaml.Unmarshal(existing, &kubeconfig); err != nil {
+		return nil, fmt.Errorf("existing kubeconfig is not valid: %w", err)
+	}
+	if kubeconfig == nil {
+		kubeconfig = map[string]interface{}{}
+	}
+	if _, ok := kubeconfig["apiVersion"]; !ok {
+		kubeconfig["apiVersion"] = "v1"
+	}
+	if _, ok := kubeconfig["kind"]; !ok {
+		kubeconfig["kind"] = "Config"
+	}
+
+	args := []string{
+		"--region", options.Region,
+		"eks", "get-token",
+		"--cluster-name", info.ClusterName,
+		"--output", "json",
+	}
+	if len(options.RoleArn) > 0 {
+		args = append(args, "--role-arn", options.RoleArn)
+	}
+	execConfig := map[string]interface{}{
+		"apiVersion": "client.authentication.k8s.io/v1beta1",
+		"command":    "aws",
+		"args":       args,
+	}
+	if len(options.Profile) > 0 {
+		execConfig["env"] = []interface{}{
+			map[string]interface{}{"name": "AWS_PROFILE", "value": options.Profile},
+		}
+	}
+
+	kubeconfig["clusters"] = upsertNamed(kubeconfig["clusters"], options.Name, "cluster", map[string]interface{}{
+		"server":                     info.ApiServerEndpoint,
+		"certificate-authority-data": info.CertificateAuthorityData,
+	})
+	kubeconfig["users"] = upsertNamed(kubeconfig["users"], options.Name, "user", map[string]interface{}{
+		"exec": execConfig,
+	})
+	kubeconfig["contexts"] = upsertNamed(kubeconfig["contexts"], options.Name, "context", map[string]interface{}{
+		"cluster": options.Name,
+		"user":    options.Name,
+	})
+	if setCurrent {
+		kubeconfig["current-context"] = options.Name
+	}
+
+	return yaml.Marshal(kubeconfig)
+}
+
+// Replace the entry of the name in a named list of kubeconfig, e.g. clusters, or append it.
+func upsertNamed(list interface{}, name string, key string, value map[string]interface{}) []interface{} {
+	entry := map[string]interface{}{
+		"name": name,
+		key:    value,
+	}
+
+	entries, _ := list.([]interface{})
+	for i, e := range entries {
+		if named, ok := e.(map[string]interface{}); ok && named["name"] == name {
+			entries[i] = entry
+			return entries
+		}
+	}
+
+	return append(entries, entry)
+}
+
+// Path of the kubeconfig that kubectl writes to, the first file of $KUBECONFIG or ~/.kube/config.
+func defaultKubeconfigPath() string {
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if len(path) > 0 {
+			return path
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".kube", "config")
+}
+
+func main() {
+	outputsFile := flag.String("outputs-file", "", "Outputs file of 'cdk deploy --outputs-file'. If it and -stack are empty, "+defaultOutputsFile+" is read.")
+	stackName := flag.String("stack", "", "Stack of the cluster. Without -outputs-file, its outputs are read from CloudFormation.")
+	region := flag.String("region", "", "Region of the stack and 'aws eks get-token', the cluster's region by default.")
+	roleArn := flag.String("role-arn", "", "Role assumed by 'aws eks get-token', the stack's kubectlRoleArn by default.")
+	profile := flag.String("profile", "", "AWS CLI profile of 'aws eks get-token'.")
+	contextName := flag.String("context", "", "Name of the kubeconfig context, the cluster name by default.")
+	kubeconfigPath := flag.String("kubeconfig", defaultKubeconfigPath(), "Kubeconfig file to write.")
+	setCurrent := flag.Bool("set-current", true, "Switch current-context to the context.")
+	flag.Parse()
+
+	var info ClusterInfo
+	var err error
+	if len(*outputsFile) == 0 && len(*stackName) > 0 {
+		info, err = ReadStackOutputs(awsCli{}, *stackName, *region)
+	} else {
+		if len(*outputsFile) == 0 {
+			*outputsFile = defaultOutputsFile
+		}
+		info, err = ReadOutputsFile(*outputsFile, *stackName)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read cluster info: %v\n", err)
+		os.Exit(1)
+	}
+
+	existing, err := os.ReadFile(*kubeconfigPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Failed to read kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := ContextOptions{
+		Name:    *contextName,
+		RoleArn: *roleArn,
+		Profile: *profile,
+		Region:  *region,
+	}
+	kubeconfig, err := MergeKubeconfig(existing, info, options, *setCurrent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*kubeconfigPath), 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*kubeconfigPath, kubeconfig, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+
+	options = options.withDefaults(info)
+	fmt.Printf("Context %s of cluster %s is written to %s, it assumes role %s.\n",
+		options.Name, info.ClusterName, *kubeconfigPath, roleName(options.RoleArn))
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testClusterName = "CDKGoExample-EKSCluster-amd64"
+	testEndpoint    = "https://AB123D8E12345CD123AA92855957B4F8.gr7.ap-northeast-1.eks.amazonaws.com"
+	testRoleArn     = "arn:aws:iam::123456789012:role/CDKGoExample-EKSCluster-EksClusterCreationRole75AA-1UKOP8JQ8R9DN"
+)
+
+// Mocked CloudFormationAPI returning a fixture file.
+type mockCloudFormation struct {
+	fixture string
+	err     error
+}
+
+func (m mockCloudFormation) DescribeStackOutputs(stackName string, region string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return os.ReadFile(m.fixture)
+}
+
+func testClusterInfo() ClusterInfo {
+	return ClusterInfo{
+		ClusterName:              testClusterName,
+		ApiServerEndpoint:        testEndpoint,
+		CertificateAuthorityData: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+		KubectlRoleArn:           testRoleArn,
+		Region:                   "ap-northeast-1",
+	}
+}
+
+func TestReadOutputsFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   string
+		stackName string
+		want      ClusterInfo
+		wantErr   string
+	}{
+		{
+			name:    "skip stacks without cluster outputs",
+			fixture: "cluster-info.json",
+			want:    testClusterInfo(),
+		},
+		{
+			name:      "stack by name",
+			fixture:   "cluster-info.json",
+			stackName: testClusterName,
+			want:      testClusterInfo(),
+		},
+		{
+			name:      "stack by name in China regions",
+			fixture:   "cluster-info-multi-cluster.json",
+			stackName: "CDKGoExample-EKSCluster-arm64",
+			want: ClusterInfo{
+				ClusterName:              "CDKGoExample-EKSCluster-arm64",
+				ApiServerEndpoint:        "https://CD456E8F12345AB123CC92855957B4F8.yl4.cn-north-1.eks.amazonaws.com.cn",
+				CertificateAuthorityData: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==",
+				KubectlRoleArn:           "arn:aws-cn:iam::123456789012:role/CDKGoExample-EKSCluster-EksClusterCreationRoleB1C2-2VLPQ9KR9S0EO",
+				Region:                   "cn-north-1",
+			},
+		},
+		{
+			name:    "several clusters need a stack name",
+			fixture: "cluster-info-multi-cluster.json",
+			wantErr: "choose one by -stack",
+		},
+		{
+			name:      "stack not in file",
+			fixture:   "cluster-info.json",
+			stackName: "CDKGoExample-EKSCluster-arm64",
+			wantErr:   "stack CDKGoExample-EKSCluster-arm64 is not in",
+		},
+		{
+			name:    "missing outputs",
+			fixture: "cluster-info-missing-outputs.json",
+			wantErr: "stack outputs certificateAuthorityData are missing",
+		},
+		{
+			name:    "not an outputs file",
+			fixture: "kubeconfig-existing.yaml",
+			wantErr: "is not an outputs file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadOutputsFile(filepath.Join("testdata", tt.fixture), tt.stackName)
+			checkResult(t, got, tt.want, err, tt.wantErr)
+		})
+	}
+}
+
+func TestReadStackOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		api     CloudFormationAPI
+		want    ClusterInfo
+		wantErr string
+	}{
+		{
+			name: "outputs of describe-stacks",
+			api:  mockCloudFormation{fixture: filepath.Join("testdata", "describe-stacks-outputs.json")},
+			want: testClusterInfo(),
+		},
+		{
+			name:    "stack without cluster outputs",
+			api:     mockCloudFormation{fixture: filepath.Join("testdata", "describe-stacks-no-cluster-outputs.json")},
+			wantErr: "stack outputs clusterName, apiServerEndpoint, certificateAuthorityData are missing",
+		},
+		{
+			name:    "not describe-stacks output",
+			api:     mockCloudFormation{fixture: filepath.Join("testdata", "cluster-info.json")},
+			wantErr: "outputs of stack " + testClusterName + " are not valid",
+		},
+		{
+			name:    "AWS CLI error",
+			api:     mockCloudFormation{err: errors.New("Stack with id " + testClusterName + " does not exist")},
+			wantErr: "does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadStackOutputs(tt.api, testClusterName, "ap-northeast-1")
+			checkResult(t, got, tt.want, err, tt.wantErr)
+		})
+	}
+}
+
+func checkResult(t *testing.T, got ClusterInfo, want ClusterInfo, err error, wantErr string) {
+	t.Helper()
+	if len(wantErr) > 0 {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("error = %v, want %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRegionFromEndpoint(t *testing.T) {
+	tests := map[string]string{
+		testEndpoint: "ap-northeast-1",
+		"https://CD456E8F12345AB123CC92855957B4F8.yl4.cn-north-1.eks.amazonaws.com.cn": "cn-north-1",
+		"https://192.168.49.2:8443": "",
+		"not a url":                 "",
+	}
+
+	for endpoint, want := range tests {
+		if got := regionFromEndpoint(endpoint); got != want {
+			t.Errorf("regionFromEndpoint(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestMergeKubeconfigNewFile(t *testing.T) {
+	got, err := MergeKubeconfig(nil, testClusterInfo(), ContextOptions{}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "kubeconfig-expected.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got kubeconfig:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeKubeconfigExistingFile(t *testing.T) {
+	existing, err := os.ReadFile(filepath.Join("testdata", "kubeconfig-existing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("replace entries of the same name", func(t *testing.T) {
+		kubeconfig := mergeAndParse(t, existing, ContextOptions{}, false)
+
+		if kubeconfig.CurrentContext != "minikube" {
+			t.Errorf("current-context = %q, want minikube", kubeconfig.CurrentContext)
+		}
+		if kubeconfig.Preferences == nil {
+			t.Error("preferences are dropped")
+		}
+		if len(kubeconfig.Clusters) != 2 || len(kubeconfig.Users) != 2 || len(kubeconfig.Contexts) != 2 {
+			t.Fatalf("got %d clusters, %d users, %d contexts, want 2 of each",
+				len(kubeconfig.Clusters), len(kubeconfig.Users), len(kubeconfig.Contexts))
+		}
+		if kubeconfig.Clusters[0].Cluster.Server != "https://192.168.49.2:8443" {
+			t.Errorf("cluster minikube is changed: %+v", kubeconfig.Clusters[0])
+		}
+		if kubeconfig.Clusters[1].Cluster.Server != testEndpoint {
+			t.Errorf("server = %q, want %q", kubeconfig.Clusters[1].Cluster.Server, testEndpoint)
+		}
+		user := kubeconfig.Users[1].User
+		if len(user.Token) > 0 || user.Exec == nil {
+			t.Fatalf("user isn't replaced by exec auth: %+v", user)
+		}
+		if got := strings.Join(user.Exec.Args, " "); !strings.HasSuffix(got, "--role-arn "+testRoleArn) {
+			t.Errorf("args = %q, want the kubectl role", got)
+		}
+	})
+
+	t.Run("add a context for another role", func(t *testing.T) {
+		roleArn := "arn:aws:iam::123456789012:role/teams/DevTeam"
+		options := ContextOptions{Name: "dev-team", RoleArn: roleArn, Profile: "dev", Region: "ap-northeast-3"}
+		kubeconfig := mergeAndParse(t, existing, options, true)
+
+		if kubeconfig.CurrentContext != "dev-team" {
+			t.Errorf("current-context = %q, want dev-team", kubeconfig.CurrentContext)
+		}
+		if len(kubeconfig.Contexts) != 3 {
+			t.Fatalf("got %d contexts, want 3", len(kubeconfig.Contexts))
+		}
+		context := kubeconfig.Contexts[2]
+		if context.Name != "dev-team" || context.Context.Cluster != "dev-team" || context.Context.User != "dev-team" {
+			t.Errorf("context = %+v, want cluster and user dev-team", context)
+		}
+		exec := kubeconfig.Users[2].User.Exec
+		wantArgs := "--region ap-northeast-3 eks get-token --cluster-name " + testClusterName + " --output json --role-arn " + roleArn
+		if got := strings.Join(exec.Args, " "); got != wantArgs {
+			t.Errorf("args = %q, want %q", got, wantArgs)
+		}
+		if len(exec.Env) != 1 || exec.Env[0].Name != "AWS_PROFILE" || exec.Env[0].Value != "dev" {
+			t.Errorf("env = %+v, want AWS_PROFILE=dev", exec.Env)
+		}
+	})
+}
+
+func TestMergeKubeconfigErrors(t *testing.T) {
+	info := testClusterInfo()
+	info.Region = ""
+	if _, err := MergeKubeconfig(nil, info, ContextOptions{}, true); err == nil || !strings.Contains(err.Error(), "set it by -region") {
+		t.Errorf("error = %v, want unknown region", err)
+	}
+
+	if _, err := MergeKubeconfig([]byte("clusters: ["), testClusterInfo(), ContextOptions{}, true); err == nil {
+		t.Error("invalid existing kubeconfig is accepted")
+	}
+}
+
+// Fields of kubeconfig checked by the tests.
+type testKubeconfig struct {
+	CurrentContext string                 `yaml:"current-context"`
+	Preferences    map[string]interface{} `yaml:"preferences"`
+	Clusters       []struct {
+		Name    string
+		Cluster struct {
+			Server string
+		}
+	}
+	Users []struct {
+		Name string
+		User struct {
+			Token string
+			Exec  *struct {
+				Args []string
+				Env  []struct {
+					Name  string
+					Value string
+				}
+			}
+		}
+	}
+	Contexts []struct {
+		Name    string
+		Context struct {
+			Cluster string
+			User    string
+		}
+	}
+}
+
+func mergeAndParse(t *testing.T, existing []byte, options ContextOptions, setCurrent bool) testKubeconfig {
+	t.Helper()
+	merged, err := MergeKubeconfig(existing, testClusterInfo(), options, setCurrent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var kubeconfig testKubeconfig
+	if err := yaml.Unmarshal(merged, &kubeconfig); err != nil {
+		t.Fatalf("merged kubeconfig is not valid: %v", err)
+	}
+
+	return kubeconfig
+}
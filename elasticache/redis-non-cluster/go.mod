@@ -5,9 +5,7 @@ go 1.17
 require (
 	github.com/aws/aws-cdk-go/awscdk/v2 v2.25.0
 	github.com/aws/constructs-go/constructs/v10 v10.0.9
+	github.com/aws/jsii-runtime-go v1.58.0
 )
 
-require (
-	github.com/Masterminds/semver/v3 v3.1.1 // indirect
-	github.com/aws/jsii-runtime-go v1.58.0 // indirect
-)
+require github.com/Masterminds/semver/v3 v3.1.1 // indirect
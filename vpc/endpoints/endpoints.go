@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Interface endpoint of an AWS service, Id is its construct id in the VPC.
+type InterfaceEndpoint struct {
+	Id      string
+	Service awsec2.InterfaceVpcEndpointAwsService
+}
+
+// VPC endpoints properties
+type VpcEndpointsProps struct {
+	// Sources allowed to call interface endpoints by HTTPS, e.g. CIDRs of instances and pods in the VPC.
+	Peers []awsec2.IPeer
+	// Interface endpoints besides ECR, STS, CloudWatch Logs and EC2.
+	ExtraInterfaceEndpoints []InterfaceEndpoint
+}
+
+// Create VPC endpoints for isolated subnets, instances can still pull images from ECR,
+// get credentials from STS, call EC2 and ship logs without NAT.
+// Images of public registries can't be pulled, mirror them to ECR.
+func NewVpcEndpoints(vpc awsec2.Vpc, props *VpcEndpointsProps) {
+	subnets := &awsec2.SubnetSelection{
+		SubnetType: awsec2.SubnetType_PRIVATE_ISOLATED,
+	}
+
+	// Gateway endpoints are free, ECR stores image layers in S3.
+	vpc.AddGatewayEndpoint(jsii.String("S3Endpoint"), &awsec2.GatewayVpcEndpointOptions{
+		Service: awsec2.GatewayVpcEndpointAwsService_S3(),
+		Subnets: &[]*awsec2.SubnetSelection{subnets},
+	})
+	vpc.AddGatewayEndpoint(jsii.String("DynamoDBEndpoint"), &awsec2.GatewayVpcEndpointOptions{
+		Service: awsec2.GatewayVpcEndpointAwsService_DYNAMODB(),
+		Subnets: &[]*awsec2.SubnetSelection{subnets},
+	})
+
+	// Interface endpoints share a security group, the default one only allows the VPC's primary CIDR.
+	endpointSG := awsec2.NewSecurityGroup(vpc, jsii.String("EndpointSG"), &awsec2.SecurityGroupProps{
+		Vpc:              vpc,
+		AllowAllOutbound: jsii.Bool(false),
+		Description:      jsii.String("Interface endpoints receive HTTPS requests from the VPC."),
+	})
+	// Peers of the VPC's IPv6 CIDR read it after it's associated with the VPC.
+	for _, child := range *vpc.Node().Children() {
+		if cidrBlock, ok := child.(awsec2.CfnVPCCidrBlock); ok {
+			endpointSG.Node().AddDependency(cidrBlock)
+		}
+	}
+	for _, peer := range props.Peers {
+		endpointSG.AddIngressRule(peer, awsec2.Port_Tcp(jsii.Number(443)), jsii.String("Allow HTTPS requests to interface endpoints."), jsii.Bool(false))
+	}
+
+	interfaceEndpoints := append([]InterfaceEndpoint{
+		{"EcrApiEndpoint", awsec2.InterfaceVpcEndpointAwsService_ECR()},
+		{"EcrDkrEndpoint", awsec2.InterfaceVpcEndpointAwsService_ECR_DOCKER()},
+		{"StsEndpoint", awsec2.InterfaceVpcEndpointAwsService_STS()},
+		{"LogsEndpoint", awsec2.InterfaceVpcEndpointAwsService_CLOUDWATCH_LOGS()},
+		{"Ec2Endpoint", awsec2.InterfaceVpcEndpointAwsService_EC2()},
+	}, props.ExtraInterfaceEndpoints...)
+	for _, endpoint := range interfaceEndpoints {
+		vpc.AddInterfaceEndpoint(jsii.String(endpoint.Id), &awsec2.InterfaceVpcEndpointOptions{
+			Service:           endpoint.Service,
+			Subnets:           subnets,
+			PrivateDnsEnabled: jsii.Bool(true),
+			Open:              jsii.Bool(false),
+			SecurityGroups:    &[]awsec2.ISecurityGroup{endpointSG},
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/aws/jsii-runtime-go"
 
 	"github.com/aws/constructs-go/constructs/v10"
+
+	"vpc-endpoints"
 )
 
 type VpcCdkStackProps struct {
@@ -34,11 +36,29 @@ func NewVpcStack(scope constructs.Construct, id string, props *VpcCdkStackProps)
 		},
 	}
 
+	var natProvider awsec2.NatProvider = nil
 	if config.CurrentDeploymentStage == config.DeploymentStage_PROD {
-		ngwNum = config.MaxAzs
+		privateSubnetType := awsec2.SubnetType_PRIVATE_WITH_NAT
+		switch config.CurrentNatMode {
+		case config.NatMode_PER_AZ:
+			ngwNum = config.MaxAzs
+		case config.NatMode_SINGLE:
+			ngwNum = 1
+		case config.NatMode_NAT_INSTANCE:
+			ngwNum = 1
+			natProvider = awsec2.NatProvider_InstanceV2(&awsec2.NatInstanceProps{
+				InstanceType: awsec2.NewInstanceType(jsii.String(config.NatInstanceType)),
+				MachineImage: awsec2.MachineImage_LatestAmazonLinux2023(&awsec2.AmazonLinux2023ImageSsmParameterProps{
+					CpuType: awsec2.AmazonLinuxCpuType_ARM_64,
+				}),
+				DefaultAllowedTraffic: awsec2.NatTrafficDirection_OUTBOUND_ONLY,
+			})
+		case config.NatMode_NONE_ENDPOINTS:
+			privateSubnetType = awsec2.SubnetType_PRIVATE_ISOLATED
+		}
 		privateSub := &awsec2.SubnetConfiguration{
 			Name:       jsii.String("PrivateSubnet"),
-			SubnetType: awsec2.SubnetType_PRIVATE_WITH_NAT,
+			SubnetType: privateSubnetType,
 			CidrMask:   jsii.Number(float64(config.SubnetMask)),
 		}
 		subnetConfigs = append(subnetConfigs, privateSub)
@@ -51,6 +71,7 @@ func NewVpcStack(scope constructs.Construct, id string, props *VpcCdkStackProps)
 		EnableDnsSupport:    jsii.Bool(true),
 		MaxAzs:              jsii.Number(float64(config.MaxAzs)),
 		NatGateways:         jsii.Number(float64(ngwNum)),
+		NatGatewayProvider:  natProvider,
 		SubnetConfiguration: &subnetConfigs,
 	})
 
@@ -60,12 +81,28 @@ func NewVpcStack(scope constructs.Construct, id string, props *VpcCdkStackProps)
 		subnetName := *stack.StackName() + "-PublicSubnet0" + strconv.Itoa(index+1)
 		awscdk.Tags_Of(subnet).Add(jsii.String("Name"), jsii.String(subnetName), &awscdk.TagProps{})
 	}
-	// Private subnets
-	for index, subnet := range *vpc.PrivateSubnets() {
+	// Private subnets, they're isolated subnets in none+endpoints NAT mode.
+	privateSubnets := vpc.PrivateSubnets()
+	if len(*privateSubnets) == 0 {
+		privateSubnets = vpc.IsolatedSubnets()
+	}
+	for index, subnet := range *privateSubnets {
 		subnetName := *stack.StackName() + "-PrivateSubnet0" + strconv.Itoa(index+1)
 		awscdk.Tags_Of(subnet).Add(jsii.String("Name"), jsii.String(subnetName), &awscdk.TagProps{})
 	}
 
+	if natInstance, ok := natProvider.(awsec2.NatInstanceProviderV2); ok {
+		natInstance.Connections().AllowFrom(awsec2.Peer_Ipv4(jsii.String(config.VpcCidr)), awsec2.Port_AllTraffic(),
+			jsii.String("Allow private subnets to reach the internet by NAT instance."))
+	}
+	if len(*vpc.IsolatedSubnets()) > 0 {
+		endpoints.NewVpcEndpoints(vpc, &endpoints.VpcEndpointsProps{
+			Peers: []awsec2.IPeer{
+				awsec2.Peer_Ipv4(jsii.String(config.VpcCidr)),
+			},
+		})
+	}
+
 	return stack
 }
 
@@ -21,3 +21,20 @@ const (
 )
 
 var VpcCidr = vpcIpv4 + "/" + strconv.Itoa(vpcMask)
+
+// How private subnets reach the internet in PROD stage.
+// per-az: a NAT gateway per AZ. single: one NAT gateway shared by all AZs.
+// nat-instance: one Graviton NAT instance. none+endpoints: no NAT, private subnets are isolated
+// and reach S3, DynamoDB, ECR, STS, CloudWatch Logs and EC2 by VPC endpoints of vpc/endpoints.
+type NatMode string
+
+const (
+	NatMode_PER_AZ         NatMode = "per-az"
+	NatMode_SINGLE         NatMode = "single"
+	NatMode_NAT_INSTANCE   NatMode = "nat-instance"
+	NatMode_NONE_ENDPOINTS NatMode = "none+endpoints"
+)
+
+const CurrentNatMode = NatMode_PER_AZ
+
+const NatInstanceType = "t4g.small"